@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/config"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	emailchange "github.com/joacolabadie/go-auth-template-v2/internal/email_change"
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+	magiclink "github.com/joacolabadie/go-auth-template-v2/internal/magic_link"
+	"github.com/joacolabadie/go-auth-template-v2/internal/maintenance"
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
+	"github.com/joacolabadie/go-auth-template-v2/internal/otp"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// migrator is implemented by the Postgres and SQLite migrators
+type migrator interface {
+	Up(ctx context.Context) (int, error)
+	Down(ctx context.Context, steps int) (int, error)
+	Status(ctx context.Context) ([]database.MigrationStatus, error)
+	Version(ctx context.Context) (int64, error)
+	LatestVersion() int64
+}
+
+type app struct {
+	cfg config.AppConfig
+	// Only the handle of the configured driver is set
+	dbPool                  *pgxpool.Pool
+	sqlDB                   *sql.DB
+	tx                      storage.Transactor
+	userRepo                user.Repository
+	refreshTokenRepo        refreshtoken.Repository
+	magicLinkRepo           magiclink.Repository
+	otpRepo                 otp.Repository
+	emailChangeRepo         emailchange.Repository
+	personalAccessTokenRepo personalaccesstoken.Repository
+}
+
+func newApp() (*app, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logging.Setup(os.Stderr, cfg.Log.Level)
+	user.SetEmailProviderRules(cfg.Auth.EmailProviderRules)
+
+	if cfg.Database.Driver == "sqlite" {
+		sqlDB, err := database.ConnectSQLite(cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		return &app{
+			cfg:                     cfg,
+			sqlDB:                   sqlDB,
+			tx:                      database.NewSQLiteTransactor(sqlDB),
+			userRepo:                user.NewSQLiteUserRepository(sqlDB),
+			refreshTokenRepo:        refreshtoken.NewSQLiteRefreshTokenRepository(sqlDB),
+			magicLinkRepo:           magiclink.NewSQLiteMagicLinkRepository(sqlDB),
+			otpRepo:                 otp.NewSQLiteOTPRepository(sqlDB),
+			emailChangeRepo:         emailchange.NewSQLiteEmailChangeRepository(sqlDB),
+			personalAccessTokenRepo: personalaccesstoken.NewSQLitePersonalAccessTokenRepository(sqlDB),
+		}, nil
+	}
+
+	dbPool, err := database.ConnectDatabase(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &app{
+		cfg:                     cfg,
+		dbPool:                  dbPool,
+		tx:                      database.NewPostgresTransactor(dbPool),
+		userRepo:                user.NewPostgresUserRepository(dbPool),
+		refreshTokenRepo:        refreshtoken.NewPostgresRefreshTokenRepository(dbPool),
+		magicLinkRepo:           magiclink.NewPostgresMagicLinkRepository(dbPool),
+		otpRepo:                 otp.NewPostgresOTPRepository(dbPool),
+		emailChangeRepo:         emailchange.NewPostgresEmailChangeRepository(dbPool),
+		personalAccessTokenRepo: personalaccesstoken.NewPostgresPersonalAccessTokenRepository(dbPool),
+	}, nil
+}
+
+func (a *app) close() {
+	if a.sqlDB != nil {
+		a.sqlDB.Close()
+		return
+	}
+
+	a.dbPool.Close()
+}
+
+func (a *app) newMigrator() (migrator, error) {
+	if a.sqlDB != nil {
+		return database.NewSQLiteMigrator(a.sqlDB)
+	}
+
+	return database.NewMigrator(a.dbPool)
+}
+
+func (a *app) databaseCheck() health.Check {
+	if a.sqlDB != nil {
+		return health.Check{Name: "sqlite", Check: a.sqlDB.PingContext}
+	}
+
+	return health.Check{Name: "postgres", Check: a.dbPool.Ping}
+}
+
+func (a *app) databaseCollector() prometheus.Collector {
+	if a.sqlDB != nil {
+		return collectors.NewDBStatsCollector(a.sqlDB, "sqlite")
+	}
+
+	return metrics.NewPoolCollector(a.dbPool)
+}
+
+// findUser accepts either a user ID or an email address
+func (a *app) findUser(ctx context.Context, identifier string) (*user.User, error) {
+	if identifier == "" {
+		return nil, errors.New("--user is required")
+	}
+
+	var u *user.User
+	var err error
+
+	if id, parseErr := uuid.Parse(identifier); parseErr == nil {
+		u, err = a.userRepo.GetUserByID(ctx, id)
+	} else {
+		u, err = a.userRepo.GetUserByEmail(ctx, identifier)
+	}
+
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("user %s not found", identifier)
+	}
+
+	return u, err
+}
+
+func (a *app) newMaintenanceWorker() *maintenance.Worker {
+	var lock maintenance.Locker = maintenance.NewLocalLocker()
+	if a.dbPool != nil {
+		lock = maintenance.NewPostgresLocker(a.dbPool)
+	}
+
+	return maintenance.NewWorker(lock, a.cfg.Maintenance.Interval, a.cfg.Maintenance.BatchSize,
+		maintenance.Task{Name: "refresh_tokens", Purge: a.refreshTokenRepo.DeleteExpiredRefreshTokens},
+		maintenance.Task{Name: "magic_links", Purge: a.magicLinkRepo.DeleteExpiredMagicLinks},
+		maintenance.Task{Name: "otp_challenges", Purge: a.otpRepo.DeleteExpiredChallenges},
+		maintenance.Task{Name: "email_changes", Purge: a.emailChangeRepo.DeleteExpiredEmailChanges},
+		maintenance.Task{Name: "personal_access_tokens", Purge: a.personalAccessTokenRepo.DeleteExpiredPersonalAccessTokens},
+		maintenance.Task{Name: "deleted_users", Purge: func(ctx context.Context, batchSize int) (int64, error) {
+			return a.userRepo.PurgeDeletedUsers(ctx, time.Now(), batchSize)
+		}},
+	)
+}
+
+func (a *app) signingKey() (auth.SigningKey, error) {
+	key, err := loadSigningKey(a.cfg.JWT.Secret, a.cfg.JWT.PrivateKeyFile, "JWT_PRIVATE_KEY_FILE")
+	if err != nil {
+		return auth.SigningKey{}, err
+	}
+
+	if a.cfg.JWT.PreviousSecret == "" && a.cfg.JWT.PreviousPrivateKeyFile == "" {
+		return key, nil
+	}
+
+	previous, err := loadSigningKey(a.cfg.JWT.PreviousSecret, a.cfg.JWT.PreviousPrivateKeyFile, "JWT_PREVIOUS_PRIVATE_KEY_FILE")
+	if err != nil {
+		return auth.SigningKey{}, err
+	}
+
+	return key.WithPrevious(previous), nil
+}
+
+func loadSigningKey(secret, privateKeyFile, fileEnv string) (auth.SigningKey, error) {
+	if privateKeyFile == "" {
+		return auth.NewHMACKey(secret), nil
+	}
+
+	pemBytes, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return auth.SigningKey{}, fmt.Errorf("failed to read %s: %w", fileEnv, err)
+	}
+
+	return auth.ParseEd25519Key(pemBytes)
+}
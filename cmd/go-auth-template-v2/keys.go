@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+func runKeys(args []string) error {
+	if len(args) == 0 || args[0] != "rotate" {
+		return errors.New("missing keys subcommand (expected rotate)")
+	}
+
+	fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	alg := fs.String("alg", "HS256", "signing algorithm, HS256 or EdDSA")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch *alg {
+	case "HS256":
+		return rotateSecret()
+	case "EdDSA":
+		return rotatePrivateKey()
+	default:
+		return fmt.Errorf("unsupported --alg %q (expected HS256 or EdDSA)", *alg)
+	}
+}
+
+func rotateSecret() error {
+	secret, err := utils.GenerateRandomToken(64)
+	if err != nil {
+		return err
+	}
+
+	// The secret is read from the environment, so rotation means handing ops a new value to deploy
+	fmt.Println("New JWT signing secret:")
+	fmt.Println()
+	fmt.Printf("JWT_SECRET=%s\n", secret)
+	fmt.Println()
+	fmt.Println("Deploy it to every instance with the current secret moved to JWT_PREVIOUS_SECRET, so access tokens")
+	fmt.Println("signed with it keep validating. Remove JWT_PREVIOUS_SECRET once they have expired.")
+
+	return nil
+}
+
+func rotatePrivateKey() error {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	signingKey, err := auth.ParseEd25519Key(pemBytes)
+	if err != nil {
+		return err
+	}
+
+	// The key goes to stdout and the instructions to stderr so the output can be redirected into a file
+	os.Stdout.Write(pemBytes)
+
+	fmt.Fprintf(os.Stderr, "\nNew Ed25519 signing key with kid %s.\n", signingKey.ID)
+	fmt.Fprintln(os.Stderr, "Save it to a file readable only by the service and point JWT_PRIVATE_KEY_FILE at it, with")
+	fmt.Fprintln(os.Stderr, "JWT_PREVIOUS_PRIVATE_KEY_FILE pointing at the current key. Both are published in /.well-known/jwks.json,")
+	fmt.Fprintln(os.Stderr, "so access tokens signed with either keep validating. Remove the previous key once they have expired.")
+
+	return nil
+}
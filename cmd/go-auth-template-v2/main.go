@@ -1,60 +1,84 @@
 package main
 
 import (
-	"log"
-
-	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
-	"github.com/joacolabadie/go-auth-template-v2/internal/config"
-	"github.com/joacolabadie/go-auth-template-v2/internal/database"
-	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
-	"github.com/joacolabadie/go-auth-template-v2/internal/routes"
-	"github.com/joacolabadie/go-auth-template-v2/internal/user"
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	"golang.org/x/time/rate"
-)
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 
-func main() {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
+	// Embedded so profile timezones validate on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
 
-	dbPool, err := database.ConnectDatabase(cfg.Database)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer dbPool.Close()
+const usage = `Usage: go-auth-template-v2 <command> [arguments]
 
-	e := echo.New()
+Commands:
+  serve                                   Start the HTTP server (default)
+  migrate [up|down [N]|status]            Manage database migrations
+  migrate check-emails [--apply]          Report users whose emails differ only by case or
+                                          provider rules, --apply recomputes the stored keys
+  user create --email E                   Create a user, see Passwords below
+  user list [--limit N] [--offset N]      List users
+  user lock --user ID|EMAIL               Lock a user and revoke their sessions
+  user unlock --user ID|EMAIL             Unlock a user
+  user delete --user ID|EMAIL             Permanently delete a user
+  user set-password --user ID|EMAIL       Replace a user's password
+  user set-app-metadata --user ID|EMAIL --metadata JSON
+                                          Merge a JSON patch into a user's app_metadata
+  sessions revoke --user ID|EMAIL         Revoke every session of a user
+  keys rotate [--alg HS256|EdDSA]         Generate a new JWT signing secret or key
+  tokens purge-expired                    Delete expired tokens, challenges and deleted users
 
-	// CORS middleware configuration
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"*"},
-		AllowHeaders: []string{"*"},
-	}))
+Passwords:
+  user create and user set-password read the password from USER_PASSWORD when
+  it is set, prompt for it when run in a terminal and otherwise read the first
+  line of stdin, so it never appears in the process list or shell history.
+`
 
-	// Rate limiting middleware configuration
-	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(20))))
+func main() {
+	command := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
 
-	// Create repositories
-	userRepo := user.NewPostgresUserRepository(dbPool)
-	refreshTokenRepo := refreshtoken.NewPostgresRefreshTokenRepository(dbPool)
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+		return
+	}
 
-	// Create services
-	authService := auth.NewService(userRepo, refreshTokenRepo, cfg.JWT.Secret, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL)
+	if err := run(command, args); err != nil {
+		slog.Error("Command failed", "command", command, "error", err)
+		os.Exit(1)
+	}
+}
 
-	// Create handlers
-	authHandler := auth.NewHandler(authService, cfg.Environment)
-	userHandler := user.NewHandler(userRepo)
+func run(command string, args []string) error {
+	if command == "keys" {
+		return runKeys(args)
+	}
 
-	// Register routes
-	routes.RegisterRoutes(e, authService, authHandler, userHandler)
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
 
-	log.Printf("Starting server on port %s...", cfg.Server.Port)
+	ctx := context.Background()
 
-	if err := e.Start(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	switch command {
+	case "serve":
+		return runServe(a)
+	case "migrate":
+		return runMigrate(ctx, a, args)
+	case "user":
+		return runUser(ctx, a, args)
+	case "sessions":
+		return runSessions(ctx, a, args)
+	case "tokens":
+		return runTokens(ctx, a, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
 	}
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 && args[0] == "check-emails" {
+		return checkEmails(ctx, a, args[1:])
+	}
+
+	migrator, err := a.newMigrator()
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Applied migrations", "count", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Reverted migrations", "count", reverted)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("%04d_%s\tapplied at %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", status.Version, status.Name)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down, status or check-emails)", command)
+	}
+
+	return nil
+}
+
+// checkEmails reports users whose emails only differ in ways EmailKey ignores,
+// which have to be merged or changed before the unique email_key index can be
+// built. It only reads columns that exist before that migration. With --apply,
+// and no duplicates, it rewrites every user's email and key with the current
+// rules in a single transaction, which is needed after enabling
+// EMAIL_PROVIDER_RULES.
+func checkEmails(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate check-emails", flag.ContinueOnError)
+	apply := fs.Bool("apply", false, "recompute stored emails and keys when there are no duplicates")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	const pageSize = 500
+
+	var users []user.UserEmail
+
+	for offset := 0; ; offset += pageSize {
+		page, err := a.userRepo.ListUserEmails(ctx, pageSize, offset)
+		if err != nil {
+			return err
+		}
+
+		users = append(users, page...)
+
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	groups := make(map[string][]user.UserEmail)
+	var keys []string
+
+	for _, u := range users {
+		key := user.EmailKey(u.Email)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], u)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	duplicates := 0
+
+	for _, key := range keys {
+		if len(groups[key]) < 2 {
+			continue
+		}
+
+		if duplicates == 0 {
+			fmt.Fprintln(w, "KEY\tID\tEMAIL\tCREATED\tLAST LOGIN")
+		}
+		duplicates++
+
+		for _, u := range groups[key] {
+			lastLogin := "-"
+			if u.LastLogin != nil {
+				lastLogin = u.LastLogin.Format(time.DateTime)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key, u.ID, u.Email, u.CreatedAt.Format(time.DateTime), lastLogin)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if duplicates > 0 {
+		return fmt.Errorf("found %d emails shared by more than one user, resolve them before migrating", duplicates)
+	}
+
+	fmt.Printf("No duplicate emails among %d users\n", len(users))
+
+	if !*apply {
+		return nil
+	}
+
+	// Either every user gets the new keys or none does, a partial run would
+	// leave keys computed with different rules
+	err := a.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for _, u := range users {
+			if err := a.userRepo.UpdateEmail(ctx, u.ID, u.Email); err != nil {
+				return fmt.Errorf("failed to update user %s: %w", u.ID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Recomputed emails and keys of %d users\n", len(users))
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/apierror"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+	"github.com/joacolabadie/go-auth-template-v2/internal/mailer"
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
+	"github.com/joacolabadie/go-auth-template-v2/internal/routes"
+	"github.com/joacolabadie/go-auth-template-v2/internal/sms"
+	"github.com/joacolabadie/go-auth-template-v2/internal/tracing"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"golang.org/x/time/rate"
+)
+
+func runServe(a *app) error {
+	cfg := a.cfg
+
+	if cfg.Database.AutoMigrate {
+		if err := runMigrate(context.Background(), a, []string{"up"}); err != nil {
+			return err
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.Environment)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HTTPErrorHandler = apierror.HTTPErrorHandler
+
+	// Request ID middleware configuration, reuses an incoming X-Request-ID when present
+	e.Use(middleware.RequestID())
+
+	// Tracing middleware configuration, continues W3C trace context from incoming requests
+	e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+
+	// Metrics middleware configuration
+	e.Use(metrics.HTTPMiddleware())
+
+	// Logging middleware configuration, one structured line per request
+	e.Use(logging.Middleware())
+
+	// CORS middleware configuration
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"*"},
+		AllowHeaders: []string{"*"},
+	}))
+
+	// Rate limiting middleware configuration
+	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(20))))
+
+	// Create mailer
+	var mail mailer.Mailer
+	if cfg.Mailer.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(cfg.Mailer.From, cfg.Mailer.SMTPHost, cfg.Mailer.SMTPPort, cfg.Mailer.SMTPUsername, cfg.Mailer.SMTPPassword)
+	} else {
+		mail = mailer.NewLogMailer(cfg.Mailer.From, cfg.Log.MessageBodies)
+	}
+
+	// Create SMS sender
+	var smsSender sms.SMSSender
+	if cfg.SMS.Provider == "http" {
+		smsSender = sms.NewHTTPSMSSender(cfg.SMS.HTTPURL, cfg.SMS.HTTPToken)
+	} else {
+		smsSender = sms.NewLogSMSSender(cfg.SMS.LogFile, cfg.Log.MessageBodies)
+	}
+
+	// Create services
+	signingKey, err := a.signingKey()
+	if err != nil {
+		return err
+	}
+
+	authService := auth.NewService(a.tx, a.userRepo, a.refreshTokenRepo, signingKey, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL, cfg.JWT.MetadataClaims)
+	magicLinkService := auth.NewMagicLinkService(authService, a.tx, a.userRepo, a.magicLinkRepo, mail, cfg.Auth.MagicLinkTTL, cfg.Server.PublicURL)
+	otpService := auth.NewOTPService(authService, a.tx, a.userRepo, a.otpRepo, mail, smsSender, cfg.Auth.OTPTTL, cfg.Auth.OTPMaxAttempts)
+	emailChangeService := auth.NewEmailChangeService(a.tx, a.userRepo, a.refreshTokenRepo, a.emailChangeRepo, otpService, mail, cfg.Auth.EmailChangeTTL, cfg.Server.PublicURL)
+	accountService := auth.NewAccountService(a.tx, a.userRepo, a.refreshTokenRepo, a.emailChangeRepo, a.personalAccessTokenRepo, otpService, cfg.Auth.AccountDeletionGracePeriod)
+	usernameService := auth.NewUsernameService(a.tx, a.userRepo, cfg.Auth.UsernameChangeCooldown)
+	personalAccessTokenService := auth.NewPersonalAccessTokenService(a.personalAccessTokenRepo, a.userRepo)
+	sessionService := auth.NewSessionService(a.refreshTokenRepo)
+
+	// Create handlers
+	authHandler := auth.NewHandler(authService, otpService, cfg.Environment, cfg.JWT.IntrospectionClients)
+	magicLinkHandler := auth.NewMagicLinkHandler(magicLinkService, otpService, cfg.Environment)
+	otpHandler := auth.NewOTPHandler(otpService, cfg.Environment)
+	emailChangeHandler := auth.NewEmailChangeHandler(emailChangeService)
+	accountHandler := auth.NewAccountHandler(accountService, cfg.Environment)
+	usernameHandler := auth.NewUsernameHandler(usernameService)
+	personalAccessTokenHandler := auth.NewPersonalAccessTokenHandler(personalAccessTokenService)
+	sessionHandler := auth.NewSessionHandler(sessionService)
+	userMetadataRules := user.MetadataRules{MaxBytes: cfg.Auth.UserMetadataMaxBytes}
+	if cfg.Auth.UserMetadataSchema != "" {
+		userMetadataRules.Schema, err = user.LoadMetadataSchema(cfg.Auth.UserMetadataSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	userHandler := user.NewHandler(a.userRepo, user.NewProfileService(a.tx, a.userRepo, userMetadataRules))
+
+	migrator, err := a.newMigrator()
+	if err != nil {
+		return err
+	}
+
+	healthHandler := health.NewHandler(3*time.Second,
+		a.databaseCheck(),
+		health.Check{Name: "migrations", Check: func(ctx context.Context) error {
+			version, err := migrator.Version(ctx)
+			if err != nil {
+				return err
+			}
+			// A newer schema is fine, a rolling deploy applies it while older pods still serve
+			if latest := migrator.LatestVersion(); version < latest {
+				return fmt.Errorf("schema is at version %d, expected at least %d", version, latest)
+			}
+			return nil
+		}},
+		health.Check{Name: "signing_key", Check: func(ctx context.Context) error {
+			return authService.CheckSigningKey()
+		}},
+		health.Check{Name: "mailer", Check: mail.Ping, Optional: true},
+	)
+
+	// Register routes
+	routes.RegisterRoutes(e, authService, personalAccessTokenService, authHandler, magicLinkHandler, otpHandler, emailChangeHandler, accountHandler, usernameHandler, personalAccessTokenHandler, sessionHandler, userHandler, healthHandler)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	maintenanceWorker := a.newMaintenanceWorker()
+
+	prometheus.MustRegister(a.databaseCollector(), metrics.NewMaintenanceCollector(maintenanceWorker))
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		maintenanceWorker.Run(workerCtx)
+	}()
+
+	serverErr := make(chan error, 1)
+	metricsErr := make(chan error, 1)
+
+	var metricsServer *http.Server
+	if cfg.Server.MetricsAddr != "" {
+		metricsServer = metrics.NewServer(cfg.Server.MetricsAddr)
+
+		go func() {
+			slog.Info("Starting metrics server", "addr", cfg.Server.MetricsAddr)
+
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				metricsErr <- fmt.Errorf("metrics server: %w", err)
+			}
+		}()
+	}
+
+	go func() {
+		slog.Info("Starting server", "port", cfg.Server.Port)
+
+		if err := e.Start(":" + cfg.Server.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		stopWorkers()
+		workers.Wait()
+		return err
+	case err := <-metricsErr:
+		stopWorkers()
+		workers.Wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	// A second signal skips the graceful path entirely
+	stop()
+
+	// Fail readiness first so the load balancer stops routing here during the delay
+	healthHandler.SetShuttingDown()
+
+	slog.Info("Shutting down, waiting before closing the listener", "delay", cfg.Server.ShutdownDelay)
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := e.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		slog.Error("Failed to drain in-flight requests", "error", shutdownErr)
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to stop the metrics server", "error", err)
+		}
+	}
+
+	stopWorkers()
+	workers.Wait()
+
+	slog.Info("Server stopped")
+
+	return shutdownErr
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+func runSessions(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return errors.New("missing sessions subcommand (expected revoke)")
+	}
+
+	fs := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	identifier := fs.String("user", "", "user ID or email")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	u, err := a.findUser(ctx, *identifier)
+	if err != nil {
+		return err
+	}
+
+	if err := a.refreshTokenRepo.RevokeUserRefreshTokens(ctx, u.ID, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked all sessions for user %s\n", u.ID)
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func runTokens(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "purge-expired" {
+		return errors.New("missing tokens subcommand (expected purge-expired)")
+	}
+
+	worker := a.newMaintenanceWorker()
+
+	if err := worker.RunOnce(ctx); err != nil {
+		return err
+	}
+
+	stats := worker.Stats()
+	if stats.LastLeaderAt == nil {
+		return errors.New("another instance is running maintenance, try again later")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tDELETED\tDURATION\tERROR")
+	for _, task := range stats.Tasks {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", task.Name, task.LastDeleted, task.LastDuration, task.LastError)
+	}
+
+	return w.Flush()
+}
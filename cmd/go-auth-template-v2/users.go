@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"golang.org/x/term"
+)
+
+func runUser(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing user subcommand (expected create, list, lock, unlock, delete, set-password or set-app-metadata)")
+	}
+
+	subcommand, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("user "+subcommand, flag.ContinueOnError)
+	identifier := fs.String("user", "", "user ID or email")
+	email := fs.String("email", "", "email address")
+	limit := fs.Int("limit", 50, "maximum number of users to list")
+	offset := fs.Int("offset", 0, "number of users to skip")
+	metadata := fs.String("metadata", "", "JSON merge patch applied to app_metadata")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "create":
+		if err := utils.Validate.Var(*email, "required,email"); err != nil {
+			return errors.New("--email must be a valid email address")
+		}
+
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+
+		hashedPassword, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		id, err := a.userRepo.CreateUser(ctx, *email, hashedPassword)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created user %s\n", id)
+
+	case "list":
+		users, err := a.userRepo.ListUsers(ctx, *limit, *offset)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tEMAIL\tCREATED\tLAST LOGIN\tSTATUS")
+		for _, u := range users {
+			lastLogin := "-"
+			if u.LastLogin != nil {
+				lastLogin = u.LastLogin.Format(time.DateTime)
+			}
+
+			status := "active"
+			if u.LockedAt != nil {
+				status = "locked"
+			} else if u.DeletionScheduledAt != nil {
+				status = "pending deletion"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.CreatedAt.Format(time.DateTime), lastLogin, status)
+		}
+
+		return w.Flush()
+
+	case "lock":
+		u, err := a.findUser(ctx, *identifier)
+		if err != nil {
+			return err
+		}
+
+		err = a.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := a.userRepo.LockUser(ctx, u.ID); err != nil {
+				return err
+			}
+
+			return a.refreshTokenRepo.RevokeUserRefreshTokens(ctx, u.ID, nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Locked user %s\n", u.ID)
+
+	case "unlock":
+		u, err := a.findUser(ctx, *identifier)
+		if err != nil {
+			return err
+		}
+
+		if err := a.userRepo.UnlockUser(ctx, u.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Unlocked user %s\n", u.ID)
+
+	case "delete":
+		u, err := a.findUser(ctx, *identifier)
+		if err != nil {
+			return err
+		}
+
+		if err := a.userRepo.DeleteUser(ctx, u.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Deleted user %s\n", u.ID)
+
+	case "set-password":
+		u, err := a.findUser(ctx, *identifier)
+		if err != nil {
+			return err
+		}
+
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+
+		hashedPassword, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		err = a.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := a.userRepo.UpdatePassword(ctx, u.ID, hashedPassword); err != nil {
+				return err
+			}
+
+			return a.refreshTokenRepo.RevokeUserRefreshTokens(ctx, u.ID, nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Updated password for user %s and revoked their sessions\n", u.ID)
+
+	case "set-app-metadata":
+		if !json.Valid([]byte(*metadata)) {
+			return errors.New("--metadata must be valid JSON")
+		}
+
+		u, err := a.findUser(ctx, *identifier)
+		if err != nil {
+			return err
+		}
+
+		rules := user.MetadataRules{MaxBytes: a.cfg.Auth.AppMetadataMaxBytes}
+
+		var updated json.RawMessage
+
+		err = a.tx.WithinTx(ctx, func(ctx context.Context) error {
+			current, err := a.userRepo.GetUserByIDForUpdate(ctx, u.ID)
+			if err != nil {
+				return err
+			}
+
+			updated, err = rules.Patch(current.AppMetadata, json.RawMessage(*metadata))
+			if err != nil {
+				return err
+			}
+
+			return a.userRepo.UpdateAppMetadata(ctx, u.ID, updated)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Updated app metadata for user %s: %s\n", u.ID, updated)
+
+	default:
+		return fmt.Errorf("unknown user subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+// readPassword takes the password from USER_PASSWORD, a prompt without echo
+// when stdin is a terminal, or the first line of stdin, never from a flag
+func readPassword() (string, error) {
+	password, ok := os.LookupEnv("USER_PASSWORD")
+
+	switch {
+	case ok:
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		first, err := promptPassword("Password: ")
+		if err != nil {
+			return "", err
+		}
+
+		second, err := promptPassword("Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+
+		if first != second {
+			return "", errors.New("passwords do not match")
+		}
+
+		password = first
+	default:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 6 {
+		return "", errors.New("password must be at least 6 characters")
+	}
+
+	return password, nil
+}
+
+func promptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+
+	return string(password), nil
+}
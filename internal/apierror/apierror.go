@@ -0,0 +1,174 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const ContentType = "application/problem+json"
+
+const (
+	CodeValidationFailed   = "validation_failed"
+	CodeEmailInUse         = "email_in_use"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeExpiredToken       = "expired_token"
+	CodeTooManyRequests    = "too_many_requests"
+	CodeInvalidCode        = "invalid_code"
+	CodeTooManyAttempts    = "too_many_attempts"
+	CodePhoneNotVerified   = "phone_not_verified"
+	CodeAccountLocked      = "account_locked"
+	CodeInvalidUsername    = "invalid_username"
+	CodeUsernameReserved   = "username_reserved"
+	CodeUsernameTaken      = "username_taken"
+	CodeUsernameCooldown   = "username_change_cooldown"
+	CodeInvalidMetadata    = "invalid_metadata"
+	CodeMetadataTooLarge   = "metadata_too_large"
+	CodeInsufficientScope  = "insufficient_scope"
+	CodeInternalError      = "internal_server_error"
+)
+
+// Error is an RFC 7807 problem details object. Code is the stable,
+// machine-readable identifier clients should switch on; Title and Detail are
+// English text meant for developers.
+type Error struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	err error
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func New(status int, code, title string) *Error {
+	return &Error{
+		Type:   "urn:problem-type:" + code,
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+type mapping struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+var mappings = []mapping{
+	{auth.ErrEmailInUse, http.StatusConflict, CodeEmailInUse, "A user with this email already exists"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials"},
+	{auth.ErrInvalidToken, http.StatusUnauthorized, CodeInvalidToken, "Invalid token"},
+	{auth.ErrExpiredToken, http.StatusUnauthorized, CodeExpiredToken, "Token has expired"},
+	{auth.ErrTooManyRequests, http.StatusTooManyRequests, CodeTooManyRequests, "Too many requests, try again later"},
+	{auth.ErrInvalidCode, http.StatusUnauthorized, CodeInvalidCode, "Invalid verification code"},
+	{auth.ErrTooManyAttempts, http.StatusTooManyRequests, CodeTooManyAttempts, "Too many verification attempts"},
+	{auth.ErrPhoneNotVerified, http.StatusConflict, CodePhoneNotVerified, "Phone number is not verified"},
+	{auth.ErrAccountLocked, http.StatusForbidden, CodeAccountLocked, "Account is locked"},
+	{auth.ErrUsernameInvalid, http.StatusBadRequest, CodeInvalidUsername, "Username is not valid"},
+	{auth.ErrUsernameReserved, http.StatusConflict, CodeUsernameReserved, "Username is reserved"},
+	{auth.ErrUsernameTaken, http.StatusConflict, CodeUsernameTaken, "Username is already taken"},
+	{auth.ErrUsernameCooldown, http.StatusTooManyRequests, CodeUsernameCooldown, "Username was changed too recently"},
+	{auth.ErrInsufficientScope, http.StatusForbidden, CodeInsufficientScope, "Token lacks the required scope"},
+	{user.ErrMetadataInvalid, http.StatusBadRequest, CodeInvalidMetadata, "Metadata is not valid"},
+	{user.ErrMetadataTooLarge, http.StatusRequestEntityTooLarge, CodeMetadataTooLarge, "Metadata is too large"},
+}
+
+// From converts any error returned by a handler into a problem. Errors that
+// aren't recognized become a 500 without leaking their message.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		copied := *apiErr
+		return &copied
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return fromValidationErrors(validationErrors)
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			apiErr := New(m.status, m.code, m.title)
+			apiErr.err = err
+			return apiErr
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return fromHTTPError(httpErr)
+	}
+
+	apiErr = New(http.StatusInternalServerError, CodeInternalError, http.StatusText(http.StatusInternalServerError))
+	apiErr.err = err
+
+	return apiErr
+}
+
+func fromValidationErrors(validationErrors validator.ValidationErrors) *Error {
+	apiErr := New(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+	apiErr.err = validationErrors
+
+	for _, fieldErr := range validationErrors {
+		apiErr.Errors = append(apiErr.Errors, FieldError{
+			Field: fieldErr.Field(),
+			Rule:  fieldErr.Tag(),
+			Param: fieldErr.Param(),
+		})
+	}
+
+	return apiErr
+}
+
+// fromHTTPError handles errors raised by echo itself (routing, binding, the
+// rate limiter) and by handlers for plain HTTP statuses, deriving the code
+// from the status text, e.g. 404 becomes "not_found".
+func fromHTTPError(httpErr *echo.HTTPError) *Error {
+	status := httpErr.Code
+	if http.StatusText(status) == "" {
+		status = http.StatusInternalServerError
+	}
+
+	statusText := http.StatusText(status)
+	code := strings.ToLower(strings.ReplaceAll(statusText, " ", "_"))
+
+	apiErr := New(status, code, statusText)
+	apiErr.err = httpErr
+
+	if message, ok := httpErr.Message.(string); ok && message != statusText && status < http.StatusInternalServerError {
+		apiErr.Detail = message
+	}
+
+	return apiErr
+}
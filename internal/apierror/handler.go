@@ -0,0 +1,41 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// HTTPErrorHandler renders every error returned by a handler or middleware as
+// application/problem+json, logging the underlying cause of server errors.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := From(err)
+	apiErr.Instance = c.Request().URL.Path
+	apiErr.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	logger := logging.FromContext(c)
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		logger.Error("Internal server error", "error", err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		var body []byte
+		body, err = json.Marshal(apiErr)
+		if err == nil {
+			err = c.Blob(apiErr.Status, ContentType, body)
+		}
+	}
+
+	if err != nil {
+		logger.Error("Failed to write error response", "error", err)
+	}
+}
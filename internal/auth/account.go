@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	emailchange "github.com/joacolabadie/go-auth-template-v2/internal/email_change"
+	"github.com/joacolabadie/go-auth-template-v2/internal/otp"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+type AccountService struct {
+	tx                  storage.Transactor
+	userRepo            user.Repository
+	refreshTokenRepo    refreshtoken.Repository
+	emailChangeRepo     emailchange.Repository
+	tokenRepo           personalaccesstoken.Repository
+	otpService          *OTPService
+	deletionGracePeriod time.Duration
+}
+
+func NewAccountService(tx storage.Transactor, userRepo user.Repository, refreshTokenRepo refreshtoken.Repository, emailChangeRepo emailchange.Repository, tokenRepo personalaccesstoken.Repository, otpService *OTPService, deletionGracePeriod time.Duration) *AccountService {
+	return &AccountService{
+		tx:                  tx,
+		userRepo:            userRepo,
+		refreshTokenRepo:    refreshTokenRepo,
+		emailChangeRepo:     emailChangeRepo,
+		tokenRepo:           tokenRepo,
+		otpService:          otpService,
+		deletionGracePeriod: deletionGracePeriod,
+	}
+}
+
+type AccountExport struct {
+	ExportedAt           time.Time                   `json:"exported_at"`
+	Profile              *user.User                  `json:"profile"`
+	PreviousUsernames    []user.PreviousUsername     `json:"previous_usernames"`
+	Sessions             []ExportSession             `json:"sessions"`
+	EmailChanges         []ExportEmailChange         `json:"email_changes"`
+	PersonalAccessTokens []ExportPersonalAccessToken `json:"personal_access_tokens"`
+}
+
+type ExportSession struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Revoked   bool      `json:"revoked"`
+}
+
+type ExportEmailChange struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	OldEmail    string     `json:"old_email"`
+	NewEmail    string     `json:"new_email"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+}
+
+// ExportPersonalAccessToken leaves out the token, which isn't stored
+type ExportPersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (s *AccountService) Export(ctx context.Context, userID uuid.UUID) (_ *AccountExport, err error) {
+	defer trace(&ctx, "auth.AccountService.Export", &err)()
+
+	profile, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames, err := s.userRepo.ListPreviousUsernames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.refreshTokenRepo.ListUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.emailChangeRepo.ListUserEmailChanges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokens, err := s.tokenRepo.ListUserPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AccountExport{
+		ExportedAt:           time.Now().UTC(),
+		Profile:              profile,
+		PreviousUsernames:    append([]user.PreviousUsername{}, usernames...),
+		Sessions:             make([]ExportSession, 0, len(tokens)),
+		EmailChanges:         make([]ExportEmailChange, 0, len(changes)),
+		PersonalAccessTokens: make([]ExportPersonalAccessToken, 0, len(accessTokens)),
+	}
+
+	for _, token := range tokens {
+		export.Sessions = append(export.Sessions, ExportSession{
+			ID:        token.ID,
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			Revoked:   token.Revoked,
+		})
+	}
+
+	for _, change := range changes {
+		export.EmailChanges = append(export.EmailChanges, ExportEmailChange{
+			ID:          change.ID,
+			CreatedAt:   change.CreatedAt,
+			OldEmail:    change.OldEmail,
+			NewEmail:    change.NewEmail,
+			ConfirmedAt: change.ConfirmedAt,
+			CancelledAt: change.CancelledAt,
+		})
+	}
+
+	for _, token := range accessTokens {
+		export.PersonalAccessTokens = append(export.PersonalAccessTokens, ExportPersonalAccessToken{
+			ID:         token.ID,
+			CreatedAt:  token.CreatedAt,
+			Name:       token.Name,
+			Prefix:     token.Prefix,
+			Scopes:     token.Scopes,
+			ExpiresAt:  token.ExpiresAt,
+			LastUsedAt: token.LastUsedAt,
+		})
+	}
+
+	return export, nil
+}
+
+func (s *AccountService) ScheduleDeletion(ctx context.Context, userID uuid.UUID, password string, challengeID uuid.UUID, code string) (_ time.Time, err error) {
+	defer trace(&ctx, "auth.AccountService.ScheduleDeletion", &err)()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if password != "" {
+		if !comparePasswords(user.PasswordHash, password) {
+			return time.Time{}, ErrInvalidCredentials
+		}
+	} else {
+		if err := s.otpService.VerifyStepUp(ctx, userID, challengeID, otp.PurposeDeleteAccount, code); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	deleteAt := time.Now().Add(s.deletionGracePeriod)
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.ScheduleDeletion(ctx, userID, deleteAt); err != nil {
+			return err
+		}
+
+		return s.refreshTokenRepo.RevokeUserRefreshTokens(ctx, userID, nil)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return deleteAt, nil
+}
+
+func (s *AccountService) CancelDeletion(ctx context.Context, userID uuid.UUID) (err error) {
+	defer trace(&ctx, "auth.AccountService.CancelDeletion", &err)()
+
+	return s.userRepo.CancelDeletion(ctx, userID)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type AccountHandler struct {
+	service     *AccountService
+	environment string
+}
+
+func NewAccountHandler(service *AccountService, environment string) *AccountHandler {
+	return &AccountHandler{
+		service:     service,
+		environment: environment,
+	}
+}
+
+func (h *AccountHandler) Export(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	export, err := h.service.Export(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="account-export.json"`)
+
+	return c.JSON(http.StatusOK, export)
+}
+
+type DeleteAccountRequest struct {
+	Password    string    `json:"password" validate:"required_without=ChallengeID"`
+	ChallengeID uuid.UUID `json:"challenge_id" validate:"required_without=Password"`
+	Code        string    `json:"code" validate:"required_with=ChallengeID,omitempty,len=6,numeric"`
+}
+
+func (h *AccountHandler) DeleteAccount(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req DeleteAccountRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	deleteAt, err := h.service.ScheduleDeletion(ctx, userID, req.Password, req.ChallengeID, req.Code)
+	if err != nil {
+		return err
+	}
+
+	ClearAuthCookies(c, h.environment)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":   "Account scheduled for deletion",
+		"delete_at": deleteAt,
+	})
+}
+
+func (h *AccountHandler) CancelDeletion(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	if err := h.service.CancelDeletion(c.Request().Context(), userID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Account deletion cancelled",
+	})
+}
@@ -66,3 +66,35 @@ func ClearAuthCookies(c echo.Context, environment string) {
 	}
 	c.SetCookie(refreshTokenCookie)
 }
+
+func SetMagicLinkNonceCookie(c echo.Context, environment string, nonce string, ttl time.Duration) {
+	isProd := environment == "production"
+
+	nonceCookie := &http.Cookie{
+		Name:     "magic_link_nonce",
+		Value:    nonce,
+		Path:     "/api/auth/magic-link",
+		Expires:  time.Now().Add(ttl),
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   isProd,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	c.SetCookie(nonceCookie)
+}
+
+func ClearMagicLinkNonceCookie(c echo.Context, environment string) {
+	isProd := environment == "production"
+
+	nonceCookie := &http.Cookie{
+		Name:     "magic_link_nonce",
+		Value:    "",
+		Path:     "/api/auth/magic-link",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		Secure:   isProd,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	c.SetCookie(nonceCookie)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	emailchange "github.com/joacolabadie/go-auth-template-v2/internal/email_change"
+	"github.com/joacolabadie/go-auth-template-v2/internal/mailer"
+	"github.com/joacolabadie/go-auth-template-v2/internal/otp"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+type EmailChangeService struct {
+	tx               storage.Transactor
+	userRepo         user.Repository
+	refreshTokenRepo refreshtoken.Repository
+	emailChangeRepo  emailchange.Repository
+	otpService       *OTPService
+	mailer           mailer.Mailer
+	ttl              time.Duration
+	publicURL        string
+}
+
+func NewEmailChangeService(tx storage.Transactor, userRepo user.Repository, refreshTokenRepo refreshtoken.Repository, emailChangeRepo emailchange.Repository, otpService *OTPService, mailer mailer.Mailer, ttl time.Duration, publicURL string) *EmailChangeService {
+	return &EmailChangeService{
+		tx:               tx,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		emailChangeRepo:  emailChangeRepo,
+		otpService:       otpService,
+		mailer:           mailer,
+		ttl:              ttl,
+		publicURL:        publicURL,
+	}
+}
+
+// RequestEmailChange needs the user's password or, without one, a completed
+// change_email step-up challenge.
+func (s *EmailChangeService) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail, password string, challengeID uuid.UUID, code, refreshTokenString string) (err error) {
+	defer trace(&ctx, "auth.EmailChangeService.RequestEmailChange", &err)()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if password != "" {
+		if !comparePasswords(user.PasswordHash, password) {
+			return ErrInvalidCredentials
+		}
+	} else {
+		if err := s.otpService.VerifyStepUp(ctx, userID, challengeID, otp.PurposeChangeEmail, code); err != nil {
+			return err
+		}
+	}
+
+	if err := s.ensureEmailAvailable(ctx, userID, newEmail); err != nil {
+		return err
+	}
+
+	// Remember which session asked for the change so it survives the revocation on confirmation.
+	// The family is kept rather than the token, which is replaced whenever the session refreshes.
+	var sessionFamilyID *uuid.UUID
+	if refreshTokenString != "" {
+		token, err := s.refreshTokenRepo.GetRefreshToken(ctx, refreshTokenString)
+		if err == nil && token.UserID == userID {
+			sessionFamilyID = &token.FamilyID
+		}
+	}
+
+	change, err := s.emailChangeRepo.CreateEmailChange(ctx, userID, user.Email, newEmail, sessionFamilyID, s.ttl)
+	if err != nil {
+		return err
+	}
+
+	confirmURL := s.publicURL + "/api/user/email/confirm?token=" + url.QueryEscape(change.Token)
+	cancelURL := s.publicURL + "/api/user/email/cancel?token=" + url.QueryEscape(change.CancelToken)
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body:    fmt.Sprintf("Use the following link to confirm this address for your account. It expires in %s.\n\n%s\n", s.ttl, confirmURL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body:    fmt.Sprintf("A request was made to change your account email to %s. If this wasn't you, cancel it using the following link.\n\n%s\n", newEmail, cancelURL),
+	})
+}
+
+func (s *EmailChangeService) ConfirmEmailChange(ctx context.Context, token string) (err error) {
+	defer trace(&ctx, "auth.EmailChangeService.ConfirmEmailChange", &err)()
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		change, err := s.emailChangeRepo.ConfirmEmailChange(ctx, token)
+		if err != nil {
+			return ErrInvalidToken
+		}
+
+		if time.Now().After(change.ExpiresAt) {
+			return ErrExpiredToken
+		}
+
+		user, err := s.userRepo.GetUserByID(ctx, change.UserID)
+		if err != nil {
+			return ErrInvalidToken
+		}
+
+		// Another change may have completed since this one was requested
+		if user.Email != change.OldEmail {
+			return ErrInvalidToken
+		}
+
+		if err := s.ensureEmailAvailable(ctx, change.UserID, change.NewEmail); err != nil {
+			return err
+		}
+
+		if err := s.userRepo.UpdateEmail(ctx, change.UserID, change.NewEmail); err != nil {
+			return err
+		}
+
+		return s.refreshTokenRepo.RevokeUserRefreshTokens(ctx, change.UserID, change.SessionFamilyID)
+	})
+}
+
+func (s *EmailChangeService) CancelEmailChange(ctx context.Context, cancelToken string) (err error) {
+	defer trace(&ctx, "auth.EmailChangeService.CancelEmailChange", &err)()
+
+	if _, err := s.emailChangeRepo.CancelEmailChange(ctx, cancelToken); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// ensureEmailAvailable lets a user keep their own address, so changing only its case is allowed
+func (s *EmailChangeService) ensureEmailAvailable(ctx context.Context, userID uuid.UUID, email string) error {
+	existing, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err == nil && existing.ID != userID {
+		return ErrEmailInUse
+	}
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type EmailChangeHandler struct {
+	service *EmailChangeService
+}
+
+func NewEmailChangeHandler(service *EmailChangeService) *EmailChangeHandler {
+	return &EmailChangeHandler{
+		service: service,
+	}
+}
+
+type ChangeEmailRequest struct {
+	NewEmail    string    `json:"new_email" validate:"required,email"`
+	Password    string    `json:"password" validate:"required_without=ChallengeID"`
+	ChallengeID uuid.UUID `json:"challenge_id" validate:"required_without=Password"`
+	Code        string    `json:"code" validate:"required_with=ChallengeID,omitempty,len=6,numeric"`
+}
+
+func (h *EmailChangeHandler) RequestEmailChange(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req ChangeEmailRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	var refreshTokenString string
+	if cookie, err := c.Cookie("refresh_token"); err == nil {
+		refreshTokenString = cookie.Value
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.service.RequestEmailChange(ctx, userID, req.NewEmail, req.Password, req.ChallengeID, req.Code, refreshTokenString); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "A confirmation link has been sent to the new email address",
+	})
+}
+
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" query:"token" validate:"required"`
+}
+
+func (h *EmailChangeHandler) ConfirmEmailChange(c echo.Context) error {
+	var req EmailChangeTokenRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.service.ConfirmEmailChange(ctx, req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Email changed successfully",
+	})
+}
+
+func (h *EmailChangeHandler) CancelEmailChange(c echo.Context) error {
+	var req EmailChangeTokenRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.service.CancelEmailChange(ctx, req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Email change cancelled successfully",
+	})
+}
@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	emailchange "github.com/joacolabadie/go-auth-template-v2/internal/email_change"
+	"github.com/joacolabadie/go-auth-template-v2/internal/mailer"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+// recordingMailer keeps sent messages so tests can follow the links in them
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+func (m *recordingMailer) Ping(ctx context.Context) error {
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+// token returns the token of the link in the last message sent to to
+func (m *recordingMailer) token(t *testing.T, to string) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != to {
+			continue
+		}
+
+		match := linkToken.FindStringSubmatch(m.messages[i].Body)
+		if match == nil {
+			t.Fatalf("message to %s has no link: %q", to, m.messages[i].Body)
+		}
+
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatalf("unescaping link token: %v", err)
+		}
+
+		return token
+	}
+
+	t.Fatalf("no message was sent to %s", to)
+	return ""
+}
+
+func TestConfirmEmailChangeKeepsRefreshedRequestingSession(t *testing.T) {
+	db := databasetest.NewSQLite(t)
+	tx := database.NewSQLiteTransactor(db)
+	userRepo := user.NewSQLiteUserRepository(db)
+	refreshTokenRepo := refreshtoken.NewSQLiteRefreshTokenRepository(db)
+	mail := &recordingMailer{}
+
+	service := newService(tx, userRepo, refreshTokenRepo)
+	emailChangeService := auth.NewEmailChangeService(tx, userRepo, refreshTokenRepo, emailchange.NewSQLiteEmailChangeRepository(db), nil, mail, time.Hour, "http://localhost")
+	ctx := context.Background()
+
+	email, newEmail := uuid.NewString()+"@example.com", uuid.NewString()+"@example.com"
+
+	userID, _, requesting, err := service.Register(ctx, email, "password", time.Hour)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, other, err := service.Login(ctx, email, "password", time.Hour)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if err := emailChangeService.RequestEmailChange(ctx, userID, newEmail, "password", uuid.Nil, "", requesting); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+
+	// The requesting session refreshes before the link is followed, replacing its token
+	_, requesting, err = service.RefreshAccessToken(ctx, requesting)
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+
+	if err := emailChangeService.ConfirmEmailChange(ctx, mail.token(t, newEmail)); err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+
+	if _, _, err := service.RefreshAccessToken(ctx, requesting); err != nil {
+		t.Errorf("refreshing the requesting session after confirmation: %v", err)
+	}
+	if _, _, err := service.RefreshAccessToken(ctx, other); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("refreshing another session after confirmation error = %v, want auth.ErrInvalidToken", err)
+	}
+}
@@ -1,10 +1,33 @@
 package auth
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
 
 var (
-	ErrEmailInUse         = errors.New("email already in use")
+	ErrEmailInUse         = user.ErrEmailInUse
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrExpiredToken       = errors.New("token has expired")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrInvalidCode        = errors.New("invalid verification code")
+	ErrTooManyAttempts    = errors.New("too many verification attempts")
+	ErrPhoneNotVerified   = errors.New("phone number not verified")
+	ErrAccountLocked      = errors.New("account is locked")
+	ErrUsernameInvalid    = user.ErrUsernameInvalid
+	ErrUsernameReserved   = user.ErrUsernameReserved
+	ErrUsernameTaken      = user.ErrUsernameTaken
+	ErrUsernameCooldown   = errors.New("username was changed too recently")
+	ErrInsufficientScope  = errors.New("token lacks the required scope")
 )
+
+type MFARequiredError struct {
+	UserID uuid.UUID
+}
+
+func (e *MFARequiredError) Error() string {
+	return "multi-factor authentication required"
+}
@@ -1,23 +1,33 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
 	service     *Service
+	otpService  *OTPService
 	environment string
+	// introspectionClients maps client IDs to their hashed secrets
+	introspectionClients map[string]string
 }
 
-func NewHandler(service *Service, environment string) *Handler {
+func NewHandler(service *Service, otpService *OTPService, environment string, introspectionClients map[string]string) *Handler {
+	hashedClients := make(map[string]string, len(introspectionClients))
+	for id, secret := range introspectionClients {
+		hashedClients[id] = utils.HashToken(secret)
+	}
+
 	return &Handler{
-		service:     service,
-		environment: environment,
+		service:              service,
+		otpService:           otpService,
+		environment:          environment,
+		introspectionClients: hashedClients,
 	}
 }
 
@@ -30,16 +40,11 @@ func (h *Handler) Register(c echo.Context) error {
 	var req RegisterRequest
 
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Failed to parse request", "details": err.Error()})
+		return err
 	}
 
 	if err := utils.Validate.Struct(req); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error":   "Failed to validate request",
-			"details": validationErrors.Error(),
-		})
+		return err
 	}
 
 	ctx := c.Request().Context()
@@ -48,15 +53,7 @@ func (h *Handler) Register(c echo.Context) error {
 
 	userID, accessToken, refreshToken, err := h.service.Register(ctx, req.Email, req.Password, refreshTokenTTL)
 	if err != nil {
-		if errors.Is(err, ErrEmailInUse) {
-			return c.JSON(http.StatusConflict, echo.Map{
-				"error": "A user with this email already exists",
-			})
-		} else {
-			return c.JSON(http.StatusInternalServerError, echo.Map{
-				"error": "Internal server error",
-			})
-		}
+		return err
 	}
 
 	accessTokenTTL := h.service.AccessTokenTTL()
@@ -70,7 +67,8 @@ func (h *Handler) Register(c echo.Context) error {
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email" validate:"required_without=Username,omitempty,email"`
+	Username string `json:"username" validate:"required_without=Email,excluded_with=Email"`
 	Password string `json:"password" validate:"required,gte=6"`
 }
 
@@ -78,33 +76,30 @@ func (h *Handler) Login(c echo.Context) error {
 	var req LoginRequest
 
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Failed to parse request", "details": err.Error()})
+		return err
 	}
 
 	if err := utils.Validate.Struct(req); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error":   "Failed to validate request",
-			"details": validationErrors.Error(),
-		})
+		return err
 	}
 
 	ctx := c.Request().Context()
 
 	refreshTokenTTL := h.service.RefreshTokenTTL()
 
-	accessToken, refreshToken, err := h.service.Login(ctx, req.Email, req.Password, refreshTokenTTL)
+	identifier := req.Email
+	if identifier == "" {
+		identifier = req.Username
+	}
+
+	accessToken, refreshToken, err := h.service.Login(ctx, identifier, req.Password, refreshTokenTTL)
 	if err != nil {
-		if errors.Is(err, ErrInvalidCredentials) {
-			return c.JSON(http.StatusUnauthorized, echo.Map{
-				"error": "Invalid credentials",
-			})
-		} else {
-			return c.JSON(http.StatusInternalServerError, echo.Map{
-				"error": "Internal server error",
-			})
+		var mfaErr *MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return mfaRequiredResponse(c, h.otpService, mfaErr.UserID)
 		}
+
+		return err
 	}
 
 	accessTokenTTL := h.service.AccessTokenTTL()
@@ -119,7 +114,7 @@ func (h *Handler) Login(c echo.Context) error {
 func (h *Handler) RefreshToken(c echo.Context) error {
 	cookie, err := c.Cookie("refresh_token")
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Missing refresh_token cookie"})
+		return echo.NewHTTPError(http.StatusUnauthorized, "Missing refresh_token cookie")
 	}
 
 	refreshTokenString := cookie.Value
@@ -128,15 +123,7 @@ func (h *Handler) RefreshToken(c echo.Context) error {
 
 	accessToken, newRefreshToken, err := h.service.RefreshAccessToken(ctx, refreshTokenString)
 	if err != nil {
-		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrExpiredToken) {
-			return c.JSON(http.StatusUnauthorized, echo.Map{
-				"error": "Invalid or expired refresh token",
-			})
-		} else {
-			return c.JSON(http.StatusInternalServerError, echo.Map{
-				"error": "Internal server error",
-			})
-		}
+		return err
 	}
 
 	accessTokenTTL := h.service.AccessTokenTTL()
@@ -160,3 +147,70 @@ func (h *Handler) Logout(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "User logged out successfully"})
 }
+
+func (h *Handler) JWKS(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+
+	return c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token" form:"token" validate:"required"`
+}
+
+// Introspect follows RFC 7662 so services that can't verify tokens locally,
+// e.g. when tokens are signed with an HMAC secret, can ask instead. Callers
+// authenticate as one of the configured clients. Invalid tokens are not an
+// error, they are reported as inactive.
+func (h *Handler) Introspect(c echo.Context) error {
+	if !h.authenticateClient(c) {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="introspection"`)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid client credentials")
+	}
+
+	var req IntrospectRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	claims, err := h.service.ValidateToken(req.Token)
+	if err != nil {
+		return c.JSON(http.StatusOK, echo.Map{"active": false})
+	}
+
+	response := echo.Map{
+		"active":     true,
+		"token_type": "access_token",
+	}
+	for _, claim := range []string{"sub", "iss", "aud", "exp", "nbf", "iat", "app_metadata", "user_metadata"} {
+		if value, ok := claims[claim]; ok {
+			response[claim] = value
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// authenticateClient checks HTTP Basic client credentials (RFC 6749 section
+// 2.3.1) against the configured introspection clients
+func (h *Handler) authenticateClient(c echo.Context) bool {
+	id, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return false
+	}
+
+	hashedSecret, known := h.introspectionClients[id]
+	if !known {
+		// Compare against a stand-in so unknown clients take as long as known ones
+		hashedSecret = utils.HashToken(id)
+	}
+
+	match := subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(hashedSecret)) == 1
+
+	return known && match
+}
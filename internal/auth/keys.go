@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey signs access tokens. HMAC keys can only be verified by holders
+// of the secret; Ed25519 keys are published as a JWKS so other services can
+// verify tokens on their own.
+type SigningKey struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	publicKey ed25519.PublicKey
+	// previous is the key in use before a rotation. Tokens it signed are still
+	// accepted, and its public half published, so they don't all fail at once.
+	previous *SigningKey
+}
+
+func NewHMACKey(secret string) SigningKey {
+	return SigningKey{
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// ParseEd25519Key reads a PKCS #8 PEM-encoded Ed25519 private key, as written
+// by "keys rotate --alg EdDSA" or "openssl genpkey -algorithm ed25519".
+func ParseEd25519Key(pemBytes []byte) (SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return SigningKey{}, errors.New("no PEM block found in signing key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return SigningKey{}, fmt.Errorf("signing key is %T, expected an Ed25519 key", key)
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	return SigningKey{
+		ID:        thumbprint(publicKey),
+		method:    jwt.SigningMethodEdDSA,
+		signKey:   privateKey,
+		verifyKey: publicKey,
+		publicKey: publicKey,
+	}, nil
+}
+
+// WithPrevious returns k, which keeps signing new tokens, also accepting
+// tokens signed with previous. Keep it until those tokens have expired.
+func (k SigningKey) WithPrevious(previous SigningKey) SigningKey {
+	previous.previous = nil
+	k.previous = &previous
+
+	return k
+}
+
+func (k SigningKey) Algorithm() string {
+	if k.method == nil {
+		return ""
+	}
+
+	return k.method.Alg()
+}
+
+func (k SigningKey) check() error {
+	switch key := k.signKey.(type) {
+	case []byte:
+		if len(key) == 0 {
+			return errors.New("jwt signing secret is empty")
+		}
+	case ed25519.PrivateKey:
+	default:
+		return errors.New("jwt signing key is not configured")
+	}
+
+	if k.previous != nil {
+		if err := k.previous.check(); err != nil {
+			return fmt.Errorf("previous key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// keys returns the current key followed by the previous one, if any
+func (k SigningKey) keys() []SigningKey {
+	if k.previous == nil {
+		return []SigningKey{k}
+	}
+
+	return []SigningKey{k, *k.previous}
+}
+
+// algorithms lists the algorithms tokens are accepted with
+func (k SigningKey) algorithms() []string {
+	var algorithms []string
+
+	for _, key := range k.keys() {
+		if alg := key.Algorithm(); alg != "" && !slices.Contains(algorithms, alg) {
+			algorithms = append(algorithms, alg)
+		}
+	}
+
+	return algorithms
+}
+
+// verificationKeys returns the keys a token with the algorithm and key ID may
+// have been signed with. HMAC keys and their tokens have no ID.
+func (k SigningKey) verificationKeys(alg, kid string) []jwt.VerificationKey {
+	var keys []jwt.VerificationKey
+
+	for _, key := range k.keys() {
+		if key.Algorithm() == alg && key.ID == kid {
+			keys = append(keys, key.verifyKey)
+		}
+	}
+
+	return keys
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of the current and previous keys. HMAC keys
+// are left out since their secret must never be published.
+func (k SigningKey) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range k.keys() {
+		if key.publicKey == nil {
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.publicKey),
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		})
+	}
+
+	return jwks
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint, used as a stable key ID
+func thumbprint(publicKey ed25519.PublicKey) string {
+	canonical := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(publicKey))
+	sum := sha256.Sum256([]byte(canonical))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
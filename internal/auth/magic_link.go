@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	magiclink "github.com/joacolabadie/go-auth-template-v2/internal/magic_link"
+	"github.com/joacolabadie/go-auth-template-v2/internal/mailer"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+type MagicLinkService struct {
+	service       *Service
+	tx            storage.Transactor
+	userRepo      user.Repository
+	magicLinkRepo magiclink.Repository
+	mailer        mailer.Mailer
+	ttl           time.Duration
+	publicURL     string
+	limiter       *middleware.RateLimiterMemoryStore
+}
+
+func NewMagicLinkService(service *Service, tx storage.Transactor, userRepo user.Repository, magicLinkRepo magiclink.Repository, mailer mailer.Mailer, ttl time.Duration, publicURL string) *MagicLinkService {
+	return &MagicLinkService{
+		service:       service,
+		tx:            tx,
+		userRepo:      userRepo,
+		magicLinkRepo: magicLinkRepo,
+		mailer:        mailer,
+		ttl:           ttl,
+		publicURL:     publicURL,
+		limiter: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Every(time.Minute),
+			Burst:     3,
+			ExpiresIn: 10 * time.Minute,
+		}),
+	}
+}
+
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email, nonce string) (err error) {
+	defer trace(&ctx, "auth.MagicLinkService.RequestMagicLink", &err)()
+
+	allowed, err := s.limiter.Allow(user.EmailKey(email))
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	// Unknown emails are ignored so the endpoint can't be used to enumerate accounts
+	u, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Links are stored under the account's email so deleting the account finds them
+	email = u.Email
+
+	link, err := s.magicLinkRepo.CreateMagicLink(ctx, email, nonce, s.ttl)
+	if err != nil {
+		return err
+	}
+
+	verifyURL := s.publicURL + "/api/auth/magic-link/verify?token=" + url.QueryEscape(link.Token)
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Your sign-in link",
+		Body:    fmt.Sprintf("Use the following link to sign in. It expires in %s and can only be used once.\n\n%s\n", s.ttl, verifyURL),
+	})
+}
+
+func (s *MagicLinkService) VerifyMagicLink(ctx context.Context, token, nonce string) (_ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.MagicLinkService.VerifyMagicLink", "magic_link", &err)()
+
+	var accessToken, refreshToken string
+	var mfaUserID uuid.UUID
+
+	// The link is only spent once tokens are issued, or once it has been exchanged for an MFA challenge
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		link, err := s.magicLinkRepo.ConsumeMagicLink(ctx, token)
+		if err != nil {
+			return ErrInvalidToken
+		}
+
+		if subtle.ConstantTimeCompare([]byte(utils.HashToken(nonce)), []byte(link.NonceHash)) != 1 {
+			return ErrInvalidToken
+		}
+
+		if time.Now().After(link.ExpiresAt) {
+			return ErrExpiredToken
+		}
+
+		user, err := s.userRepo.GetUserByEmail(ctx, link.Email)
+		if err != nil {
+			return ErrInvalidToken
+		}
+
+		if user.PhoneVerified {
+			mfaUserID = user.ID
+			return nil
+		}
+
+		accessToken, refreshToken, err = s.service.IssueTokens(ctx, user.ID)
+
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if mfaUserID != uuid.Nil {
+		return "", "", &MFARequiredError{UserID: mfaUserID}
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (s *MagicLinkService) TTL() time.Duration {
+	return s.ttl
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type MagicLinkHandler struct {
+	service     *MagicLinkService
+	otpService  *OTPService
+	environment string
+}
+
+func NewMagicLinkHandler(service *MagicLinkService, otpService *OTPService, environment string) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		service:     service,
+		otpService:  otpService,
+		environment: environment,
+	}
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h *MagicLinkHandler) RequestMagicLink(c echo.Context) error {
+	var req MagicLinkRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.service.RequestMagicLink(ctx, req.Email, nonce); err != nil {
+		return err
+	}
+
+	SetMagicLinkNonceCookie(c, h.environment, nonce, h.service.TTL())
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "If an account exists for this email, a sign-in link has been sent",
+	})
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" query:"token" form:"token" validate:"required"`
+}
+
+// magicLinkPage asks the user to confirm the sign-in, so only a POST spends
+// the link. Mail scanners and link previews fetch the GET and would otherwise
+// use it up before the user clicks.
+var magicLinkPage = template.Must(template.New("magic_link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// ConfirmMagicLink serves the page the emailed link opens. It doesn't touch
+// the link, which VerifyMagicLink consumes once the page is submitted.
+func (h *MagicLinkHandler) ConfirmMagicLink(c echo.Context) error {
+	var req VerifyMagicLinkRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	// The token is in the URL, so keep it out of caches and Referer headers
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Referrer-Policy", "no-referrer")
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+
+	return magicLinkPage.Execute(c.Response(), struct{ Action, Token string }{
+		Action: c.Request().URL.Path,
+		Token:  req.Token,
+	})
+}
+
+func (h *MagicLinkHandler) VerifyMagicLink(c echo.Context) error {
+	var req VerifyMagicLinkRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	cookie, err := c.Cookie("magic_link_nonce")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Missing magic_link_nonce cookie")
+	}
+
+	ctx := c.Request().Context()
+
+	accessToken, refreshToken, err := h.service.VerifyMagicLink(ctx, req.Token, cookie.Value)
+	if err != nil {
+		var mfaErr *MFARequiredError
+		if errors.As(err, &mfaErr) {
+			ClearMagicLinkNonceCookie(c, h.environment)
+
+			return mfaRequiredResponse(c, h.otpService, mfaErr.UserID)
+		}
+
+		return err
+	}
+
+	accessTokenTTL := h.service.service.AccessTokenTTL()
+	refreshTokenTTL := h.service.service.RefreshTokenTTL()
+
+	ClearMagicLinkNonceCookie(c, h.environment)
+	SetAuthCookies(c, h.environment, accessToken, refreshToken, accessTokenTTL, refreshTokenTTL)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "User logged in successfully",
+	})
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+func recordAttempt(flow string, err error) {
+	var mfaErr *MFARequiredError
+
+	switch {
+	case err == nil:
+		metrics.AuthAttempts.WithLabelValues(flow, "success", "").Inc()
+		return
+	case errors.As(err, &mfaErr):
+		metrics.AuthAttempts.WithLabelValues(flow, "mfa_required", "").Inc()
+		return
+	}
+
+	reason := "error"
+
+	switch {
+	case errors.Is(err, ErrInvalidCredentials):
+		reason = "invalid_credentials"
+	case errors.Is(err, ErrEmailInUse):
+		reason = "email_in_use"
+	case errors.Is(err, ErrInvalidToken):
+		reason = "invalid_token"
+	case errors.Is(err, ErrExpiredToken):
+		reason = "expired_token"
+	case errors.Is(err, ErrInvalidCode):
+		reason = "invalid_code"
+	case errors.Is(err, ErrTooManyRequests):
+		reason = "rate_limited"
+	case errors.Is(err, ErrAccountLocked):
+		reason = "account_locked"
+		metrics.Lockouts.WithLabelValues(reason).Inc()
+	case errors.Is(err, ErrTooManyAttempts):
+		reason = "too_many_attempts"
+		metrics.Lockouts.WithLabelValues(reason).Inc()
+	}
+
+	metrics.AuthAttempts.WithLabelValues(flow, "failure", reason).Inc()
+}
+
+// hashPassword and comparePasswords time the bcrypt work of the auth flows
+func hashPassword(password string) (string, error) {
+	defer observeHashDuration("hash", time.Now())
+
+	return utils.HashPassword(password)
+}
+
+func comparePasswords(hashedPassword, password string) bool {
+	defer observeHashDuration("compare", time.Now())
+
+	return utils.ComparePasswords(hashedPassword, password)
+}
+
+func observeHashDuration(operation string, start time.Time) {
+	metrics.PasswordHashDuration.WithLabelValues("bcrypt", operation).Observe(time.Since(start).Seconds())
+}
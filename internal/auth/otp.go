@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/mailer"
+	"github.com/joacolabadie/go-auth-template-v2/internal/otp"
+	"github.com/joacolabadie/go-auth-template-v2/internal/sms"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+const otpCodeDigits = 6
+
+type OTPService struct {
+	service     *Service
+	tx          storage.Transactor
+	userRepo    user.Repository
+	otpRepo     otp.Repository
+	mailer      mailer.Mailer
+	smsSender   sms.SMSSender
+	ttl         time.Duration
+	maxAttempts int
+	limiter     *middleware.RateLimiterMemoryStore
+	smsLimiter  *middleware.RateLimiterMemoryStore
+}
+
+func NewOTPService(service *Service, tx storage.Transactor, userRepo user.Repository, otpRepo otp.Repository, mailer mailer.Mailer, smsSender sms.SMSSender, ttl time.Duration, maxAttempts int) *OTPService {
+	return &OTPService{
+		service:     service,
+		tx:          tx,
+		userRepo:    userRepo,
+		otpRepo:     otpRepo,
+		mailer:      mailer,
+		smsSender:   smsSender,
+		ttl:         ttl,
+		maxAttempts: maxAttempts,
+		limiter: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Every(time.Minute),
+			Burst:     3,
+			ExpiresIn: 10 * time.Minute,
+		}),
+		smsLimiter: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Every(5 * time.Minute),
+			Burst:     3,
+			ExpiresIn: 30 * time.Minute,
+		}),
+	}
+}
+
+func (s *OTPService) StartEmailLogin(ctx context.Context, email string) (_ uuid.UUID, err error) {
+	defer trace(&ctx, "auth.OTPService.StartEmailLogin", &err)()
+
+	allowed, err := s.limiter.Allow(user.EmailKey(email))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !allowed {
+		return uuid.Nil, ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Hand out a challenge ID that will never verify so unknown emails look the same as known ones
+		return uuid.New(), nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return s.issueChallenge(ctx, user.ID, otp.PurposeLogin, otp.ChannelEmail, user.Email)
+}
+
+func (s *OTPService) VerifyEmailLogin(ctx context.Context, challengeID uuid.UUID, code string) (_ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.OTPService.VerifyEmailLogin", "email_otp", &err)()
+
+	var accessToken, refreshToken string
+	var mfaUserID uuid.UUID
+
+	err = s.verifyChallenge(ctx, challengeID, otp.PurposeLogin, code, func(ctx context.Context, challenge *otp.Challenge) error {
+		user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+
+		if user.PhoneVerified {
+			mfaUserID = user.ID
+			return nil
+		}
+
+		accessToken, refreshToken, err = s.service.IssueTokens(ctx, user.ID)
+
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if mfaUserID != uuid.Nil {
+		return "", "", &MFARequiredError{UserID: mfaUserID}
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (s *OTPService) StartMFA(ctx context.Context, userID uuid.UUID) (_ uuid.UUID, err error) {
+	defer trace(&ctx, "auth.OTPService.StartMFA", &err)()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !user.PhoneVerified || user.PhoneNumber == nil {
+		return uuid.Nil, ErrPhoneNotVerified
+	}
+
+	if err := s.allowSMS(*user.PhoneNumber); err != nil {
+		return uuid.Nil, err
+	}
+
+	return s.issueChallenge(ctx, user.ID, otp.PurposeMFA, otp.ChannelSMS, *user.PhoneNumber)
+}
+
+func (s *OTPService) VerifyMFA(ctx context.Context, challengeID uuid.UUID, code string) (_ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.OTPService.VerifyMFA", "mfa", &err)()
+
+	var accessToken, refreshToken string
+
+	err = s.verifyChallenge(ctx, challengeID, otp.PurposeMFA, code, func(ctx context.Context, challenge *otp.Challenge) error {
+		var err error
+		accessToken, refreshToken, err = s.service.IssueTokens(ctx, challenge.UserID)
+
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (s *OTPService) StartPhoneVerification(ctx context.Context, userID uuid.UUID, phoneNumber string) (_ uuid.UUID, err error) {
+	defer trace(&ctx, "auth.OTPService.StartPhoneVerification", &err)()
+
+	if err := s.allowSMS(phoneNumber); err != nil {
+		return uuid.Nil, err
+	}
+
+	// The number is only kept on the challenge until it's verified, so a
+	// verified number, and MFA with it, stays in place until then
+	return s.issueChallenge(ctx, userID, otp.PurposeVerifyPhone, otp.ChannelSMS, phoneNumber)
+}
+
+func (s *OTPService) VerifyPhone(ctx context.Context, userID uuid.UUID, challengeID uuid.UUID, code string) (err error) {
+	defer trace(&ctx, "auth.OTPService.VerifyPhone", &err)()
+
+	return s.verifyChallenge(ctx, challengeID, otp.PurposeVerifyPhone, code, func(ctx context.Context, challenge *otp.Challenge) error {
+		if challenge.UserID != userID {
+			return ErrInvalidToken
+		}
+
+		return s.userRepo.SetVerifiedPhoneNumber(ctx, userID, challenge.Destination)
+	})
+}
+
+func (s *OTPService) StartStepUp(ctx context.Context, userID uuid.UUID, purpose string) (_ uuid.UUID, err error) {
+	defer trace(&ctx, "auth.OTPService.StartStepUp", &err)()
+
+	allowed, err := s.limiter.Allow(userID.String())
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !allowed {
+		return uuid.Nil, ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return s.issueChallenge(ctx, user.ID, purpose, otp.ChannelEmail, user.Email)
+}
+
+func (s *OTPService) VerifyStepUp(ctx context.Context, userID uuid.UUID, challengeID uuid.UUID, purpose, code string) (err error) {
+	defer trace(&ctx, "auth.OTPService.VerifyStepUp", &err)()
+
+	return s.verifyChallenge(ctx, challengeID, purpose, code, func(ctx context.Context, challenge *otp.Challenge) error {
+		if challenge.UserID != userID {
+			return ErrInvalidToken
+		}
+
+		return nil
+	})
+}
+
+func (s *OTPService) allowSMS(phoneNumber string) error {
+	allowed, err := s.smsLimiter.Allow(phoneNumber)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	return nil
+}
+
+func (s *OTPService) issueChallenge(ctx context.Context, userID uuid.UUID, purpose, channel, destination string) (uuid.UUID, error) {
+	code, err := utils.GenerateNumericCode(otpCodeDigits)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	challenge, err := s.otpRepo.CreateChallenge(ctx, userID, purpose, channel, destination, code, s.ttl)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	switch channel {
+	case otp.ChannelSMS:
+		err = s.smsSender.Send(ctx, destination, fmt.Sprintf("Your verification code is %s", code))
+	default:
+		err = s.mailer.Send(ctx, mailer.Message{
+			To:      destination,
+			Subject: "Your verification code",
+			Body:    fmt.Sprintf("Your verification code is %s. It expires in %s.\n", code, s.ttl),
+		})
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return challenge.ID, nil
+}
+
+// verifyChallenge records the attempt on its own so a wrong code always counts,
+// then consumes the challenge and runs fn in one transaction.
+func (s *OTPService) verifyChallenge(ctx context.Context, challengeID uuid.UUID, purpose, code string, fn func(ctx context.Context, challenge *otp.Challenge) error) error {
+	challenge, err := s.otpRepo.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if challenge.Purpose != purpose || challenge.ConsumedAt != nil {
+		return ErrInvalidToken
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		return ErrExpiredToken
+	}
+
+	_, err = s.otpRepo.IncrementAttempts(ctx, challengeID, s.maxAttempts)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrTooManyAttempts
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(code)), []byte(challenge.CodeHash)) != 1 {
+		return ErrInvalidCode
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.otpRepo.ConsumeChallenge(ctx, challengeID); err != nil {
+			return ErrInvalidToken
+		}
+
+		return fn(ctx, challenge)
+	})
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type OTPHandler struct {
+	service     *OTPService
+	environment string
+}
+
+func NewOTPHandler(service *OTPService, environment string) *OTPHandler {
+	return &OTPHandler{
+		service:     service,
+		environment: environment,
+	}
+}
+
+type StartEmailOTPRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h *OTPHandler) StartEmailLogin(c echo.Context) error {
+	var req StartEmailOTPRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	challengeID, err := h.service.StartEmailLogin(ctx, req.Email)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":      "If an account exists for this email, a verification code has been sent",
+		"challenge_id": challengeID,
+	})
+}
+
+type VerifyOTPRequest struct {
+	ChallengeID uuid.UUID `json:"challenge_id" validate:"required"`
+	Code        string    `json:"code" validate:"required,len=6,numeric"`
+}
+
+func (h *OTPHandler) VerifyEmailLogin(c echo.Context) error {
+	var req VerifyOTPRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	accessToken, refreshToken, err := h.service.VerifyEmailLogin(ctx, req.ChallengeID, req.Code)
+	if err != nil {
+		var mfaErr *MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return mfaRequiredResponse(c, h.service, mfaErr.UserID)
+		}
+
+		return err
+	}
+
+	accessTokenTTL := h.service.service.AccessTokenTTL()
+	refreshTokenTTL := h.service.service.RefreshTokenTTL()
+
+	SetAuthCookies(c, h.environment, accessToken, refreshToken, accessTokenTTL, refreshTokenTTL)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "User logged in successfully",
+	})
+}
+
+func (h *OTPHandler) VerifyMFA(c echo.Context) error {
+	var req VerifyOTPRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	accessToken, refreshToken, err := h.service.VerifyMFA(ctx, req.ChallengeID, req.Code)
+	if err != nil {
+		return err
+	}
+
+	accessTokenTTL := h.service.service.AccessTokenTTL()
+	refreshTokenTTL := h.service.service.RefreshTokenTTL()
+
+	SetAuthCookies(c, h.environment, accessToken, refreshToken, accessTokenTTL, refreshTokenTTL)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "User logged in successfully",
+	})
+}
+
+type StartPhoneVerificationRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,e164"`
+}
+
+func (h *OTPHandler) StartPhoneVerification(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req StartPhoneVerificationRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	challengeID, err := h.service.StartPhoneVerification(ctx, userID, req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":      "A verification code has been sent",
+		"challenge_id": challengeID,
+	})
+}
+
+func (h *OTPHandler) VerifyPhone(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req VerifyOTPRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.service.VerifyPhone(ctx, userID, req.ChallengeID, req.Code); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Phone number verified successfully",
+	})
+}
+
+type StartStepUpRequest struct {
+	Purpose string `json:"purpose" validate:"required,oneof=change_email delete_account"`
+}
+
+func (h *OTPHandler) StartStepUp(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req StartStepUpRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	challengeID, err := h.service.StartStepUp(ctx, userID, req.Purpose)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":      "A verification code has been sent",
+		"challenge_id": challengeID,
+	})
+}
+
+func mfaRequiredResponse(c echo.Context, service *OTPService, userID uuid.UUID) error {
+	challengeID, err := service.StartMFA(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":      "Multi-factor authentication required",
+		"mfa_required": true,
+		"channel":      "sms",
+		"challenge_id": challengeID,
+	})
+}
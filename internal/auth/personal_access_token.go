@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+type PersonalAccessTokenService struct {
+	tokenRepo personalaccesstoken.Repository
+	userRepo  user.Repository
+}
+
+func NewPersonalAccessTokenService(tokenRepo personalaccesstoken.Repository, userRepo user.Repository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// Create issues a token for the user. The returned token is the only place the
+// secret appears, only its hash is stored.
+func (s *PersonalAccessTokenService) Create(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (_ *personalaccesstoken.PersonalAccessToken, err error) {
+	defer trace(&ctx, "auth.PersonalAccessTokenService.Create", &err)()
+
+	return s.tokenRepo.CreatePersonalAccessToken(ctx, userID, name, scopes, expiresAt)
+}
+
+func (s *PersonalAccessTokenService) List(ctx context.Context, userID uuid.UUID) (_ []personalaccesstoken.PersonalAccessToken, err error) {
+	defer trace(&ctx, "auth.PersonalAccessTokenService.List", &err)()
+
+	return s.tokenRepo.ListUserPersonalAccessTokens(ctx, userID)
+}
+
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, userID, id uuid.UUID) (err error) {
+	defer trace(&ctx, "auth.PersonalAccessTokenService.Revoke", &err)()
+
+	return s.tokenRepo.RevokePersonalAccessToken(ctx, userID, id)
+}
+
+// Authenticate returns the ID of the user the token belongs to if the token is
+// live and was granted scope, and records that it was used.
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, rawToken, scope string) (_ uuid.UUID, err error) {
+	defer trace(&ctx, "auth.PersonalAccessTokenService.Authenticate", &err)()
+
+	if !personalaccesstoken.IsPersonalAccessToken(rawToken) {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetPersonalAccessToken(ctx, rawToken)
+	if errors.Is(err, storage.ErrNotFound) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return uuid.Nil, ErrExpiredToken
+	}
+
+	if !token.HasScope(scope) {
+		return uuid.Nil, ErrInsufficientScope
+	}
+
+	u, err := s.userRepo.GetUserByID(ctx, token.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if u.LockedAt != nil {
+		return uuid.Nil, ErrAccountLocked
+	}
+
+	// Unlike a session, a token can't be used to cancel a pending deletion
+	if u.DeletionScheduledAt != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	if err := s.tokenRepo.TouchPersonalAccessToken(ctx, token.ID); err != nil {
+		return uuid.Nil, err
+	}
+
+	return token.UserID, nil
+}
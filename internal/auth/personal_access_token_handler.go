@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type PersonalAccessTokenHandler struct {
+	service *PersonalAccessTokenService
+}
+
+func NewPersonalAccessTokenHandler(service *PersonalAccessTokenService) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{
+		service: service,
+	}
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Token is only set in the response to creating the token
+	Token string `json:"token,omitempty"`
+}
+
+func (h *PersonalAccessTokenHandler) ListTokens(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	tokens, err := h.service.List(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	response := make([]PersonalAccessTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		response = append(response, newPersonalAccessTokenResponse(&tokens[i]))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"tokens": response,
+	})
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=profile:read profile:write account:export"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
+
+func (h *PersonalAccessTokenHandler) CreateToken(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req CreatePersonalAccessTokenRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	token, err := h.service.Create(c.Request().Context(), userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	response := newPersonalAccessTokenResponse(token)
+	response.Token = token.Token
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *PersonalAccessTokenHandler) RevokeToken(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	err = h.service.Revoke(c.Request().Context(), userID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Token revoked successfully"})
+}
+
+func newPersonalAccessTokenResponse(token *personalaccesstoken.PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         token.ID.String(),
+		CreatedAt:  token.CreatedAt,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.Scopes,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
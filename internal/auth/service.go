@@ -2,91 +2,158 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
 	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
 	"github.com/joacolabadie/go-auth-template-v2/internal/user"
-	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
 )
 
 type Service struct {
+	tx               storage.Transactor
 	userRepo         user.Repository
 	refreshTokenRepo refreshtoken.Repository
-	jwtSecret        []byte
+	signingKey       SigningKey
+	issuer           string
+	audience         string
 	accessTokenTTL   time.Duration
 	refreshTokenTTL  time.Duration
+	metadataClaims   []string
 }
 
-func NewService(userRepo user.Repository, refreshTokenRepo refreshtoken.Repository, jwtSecret string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration) *Service {
+func NewService(tx storage.Transactor, userRepo user.Repository, refreshTokenRepo refreshtoken.Repository, signingKey SigningKey, issuer, audience string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration, metadataClaims []string) *Service {
 	return &Service{
+		tx:               tx,
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
-		jwtSecret:        []byte(jwtSecret),
+		signingKey:       signingKey,
+		issuer:           issuer,
+		audience:         audience,
 		accessTokenTTL:   accessTokenTTL,
 		refreshTokenTTL:  refreshTokenTTL,
+		metadataClaims:   metadataClaims,
 	}
 }
 
-func (s *Service) Register(ctx context.Context, email, password string, refreshTokenTTL time.Duration) (uuid.UUID, string, string, error) {
-	_, err := s.userRepo.GetUserByEmail(ctx, email)
+func (s *Service) Register(ctx context.Context, email, password string, refreshTokenTTL time.Duration) (_ uuid.UUID, _ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.Service.Register", "register", &err)()
+
+	_, err = s.userRepo.GetUserByEmail(ctx, email)
 	if err == nil {
 		return uuid.Nil, "", "", ErrEmailInUse
 	}
-	if !errors.Is(err, pgx.ErrNoRows) {
+	if !errors.Is(err, storage.ErrNotFound) {
 		return uuid.Nil, "", "", err
 	}
 
-	hashedPassword, err := utils.HashPassword(password)
-	if err != nil {
-		return uuid.Nil, "", "", err
-	}
+	_, hashSpan := tracer.Start(ctx, "bcrypt.Hash")
+	hashedPassword, err := hashPassword(password)
+	hashSpan.End()
 
-	id, err := s.userRepo.CreateUser(ctx, email, hashedPassword)
 	if err != nil {
 		return uuid.Nil, "", "", err
 	}
 
-	if err := s.userRepo.UpdateLastLogin(ctx, id); err != nil {
-		return uuid.Nil, "", "", err
-	}
+	var id uuid.UUID
+	var accessToken, refreshToken string
 
-	accessToken, err := s.generateAccessToken(id)
-	if err != nil {
-		return uuid.Nil, "", "", err
-	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		id, err = s.userRepo.CreateUser(ctx, email, hashedPassword)
+		if err != nil {
+			return err
+		}
 
-	refreshToken, err := s.refreshTokenRepo.CreateRefreshToken(ctx, id, refreshTokenTTL)
+		user, err := s.userRepo.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		accessToken, refreshToken, err = s.issueTokens(ctx, user, refreshTokenTTL)
+
+		return err
+	})
 	if err != nil {
 		return uuid.Nil, "", "", err
 	}
 
-	return id, accessToken, refreshToken.Token, nil
+	return id, accessToken, refreshToken, nil
 }
 
-func (s *Service) Login(ctx context.Context, email, password string, refreshTokenTTL time.Duration) (string, string, error) {
-	user, err := s.userRepo.GetUserByEmail(ctx, email)
+// Login authenticates by email or username; identifiers with an @ are emails,
+// which usernames can't contain.
+func (s *Service) Login(ctx context.Context, identifier, password string, refreshTokenTTL time.Duration) (_ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.Service.Login", "login", &err)()
+
+	lookup := s.userRepo.GetUserByUsername
+	if strings.Contains(identifier, "@") {
+		lookup = s.userRepo.GetUserByEmail
+	}
+
+	user, err := lookup(ctx, identifier)
 	if err != nil {
 		return "", "", ErrInvalidCredentials
 	}
 
-	if !utils.ComparePasswords(user.PasswordHash, password) {
+	_, hashSpan := tracer.Start(ctx, "bcrypt.Compare")
+	passwordMatches := comparePasswords(user.PasswordHash, password)
+	hashSpan.End()
+
+	if !passwordMatches {
 		return "", "", ErrInvalidCredentials
 	}
 
-	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+	if user.LockedAt != nil {
+		return "", "", ErrAccountLocked
+	}
+
+	if user.PhoneVerified {
+		return "", "", &MFARequiredError{UserID: user.ID}
+	}
+
+	return s.issueTokens(ctx, user, refreshTokenTTL)
+}
+
+func (s *Service) IssueTokens(ctx context.Context, userID uuid.UUID) (_ string, _ string, err error) {
+	defer trace(&ctx, "auth.Service.IssueTokens", &err)()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
 		return "", "", err
 	}
 
-	accessToken, err := s.generateAccessToken(user.ID)
+	if user.LockedAt != nil {
+		return "", "", ErrAccountLocked
+	}
+
+	return s.issueTokens(ctx, user, s.refreshTokenTTL)
+}
+
+func (s *Service) issueTokens(ctx context.Context, user *user.User, refreshTokenTTL time.Duration) (string, string, error) {
+	accessToken, err := s.generateAccessToken(user.ID, s.userClaims(user))
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken, err := s.refreshTokenRepo.CreateRefreshToken(ctx, user.ID, refreshTokenTTL)
+	var refreshToken *refreshtoken.RefreshToken
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = s.refreshTokenRepo.CreateRefreshToken(ctx, user.ID, refreshTokenTTL)
+
+		return err
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -94,22 +161,33 @@ func (s *Service) Login(ctx context.Context, email, password string, refreshToke
 	return accessToken, refreshToken.Token, nil
 }
 
-func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+func (s *Service) Logout(ctx context.Context, refreshToken string) (err error) {
+	defer trace(&ctx, "auth.Service.Logout", &err)()
+
 	return s.refreshTokenRepo.RevokeRefreshToken(ctx, refreshToken)
 }
 
-func (s *Service) generateAccessToken(id uuid.UUID) (string, error) {
-	expirationTime := time.Now().Add(s.accessTokenTTL)
+func (s *Service) generateAccessToken(id uuid.UUID, extraClaims jwt.MapClaims) (string, error) {
+	now := time.Now()
 
 	claims := jwt.MapClaims{
 		"sub": id.String(),
-		"exp": expirationTime.Unix(),
-		"iat": time.Now().Unix(),
+		"iss": s.issuer,
+		"aud": s.audience,
+		"exp": now.Add(s.accessTokenTTL).Unix(),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+	}
+	for name, value := range extraClaims {
+		claims[name] = value
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(s.signingKey.method, claims)
+	if s.signingKey.ID != "" {
+		token.Header["kid"] = s.signingKey.ID
+	}
 
-	tokenString, err := token.SignedString(s.jwtSecret)
+	tokenString, err := token.SignedString(s.signingKey.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -117,13 +195,67 @@ func (s *Service) generateAccessToken(id uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
+// userClaims picks the configured metadata keys, e.g. app_metadata.plan, into
+// app_metadata and user_metadata claim objects. Keys the user doesn't have are
+// left out.
+func (s *Service) userClaims(user *user.User) jwt.MapClaims {
+	if len(s.metadataClaims) == 0 {
+		return nil
+	}
+
+	metadata := map[string]json.RawMessage{
+		"app_metadata":  user.AppMetadata,
+		"user_metadata": user.UserMetadata,
+	}
+	decoded := map[string]map[string]any{}
+	claims := jwt.MapClaims{}
+
+	for _, claim := range s.metadataClaims {
+		field, key, _ := strings.Cut(claim, ".")
+
+		values, ok := decoded[field]
+		if !ok {
+			// Metadata was validated on the way in, anything unreadable is just skipped
+			_ = json.Unmarshal(metadata[field], &values)
+			decoded[field] = values
+		}
+
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+
+		object, _ := claims[field].(map[string]any)
+		if object == nil {
+			object = map[string]any{}
+			claims[field] = object
+		}
+		object[key] = value
+	}
+
+	return claims
+}
+
 func (s *Service) ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	// Tokens signed before a key rotation are checked against the previous key
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		keys := s.signingKey.verificationKeys(token.Method.Alg(), kid)
+		if len(keys) == 0 {
 			return nil, ErrInvalidToken
 		}
-		return s.jwtSecret, nil
-	})
+
+		return jwt.VerificationKeySet{Keys: keys}, nil
+	}
+
+	token, err := jwt.Parse(
+		tokenString,
+		keyFunc,
+		jwt.WithValidMethods(s.signingKey.algorithms()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+	)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -139,13 +271,16 @@ func (s *Service) ValidateToken(tokenString string) (jwt.MapClaims, error) {
 	return nil, ErrInvalidToken
 }
 
-func (s *Service) RefreshAccessToken(ctx context.Context, refreshTokenString string) (string, string, error) {
+func (s *Service) RefreshAccessToken(ctx context.Context, refreshTokenString string) (_ string, _ string, err error) {
+	defer traceAttempt(&ctx, "auth.Service.RefreshAccessToken", "refresh", &err)()
+
 	token, err := s.refreshTokenRepo.GetRefreshToken(ctx, refreshTokenString)
 	if err != nil {
 		return "", "", ErrInvalidToken
 	}
 
 	if token.Revoked {
+		metrics.RefreshTokenReuseDetections.Inc()
 		return "", "", ErrInvalidToken
 	}
 
@@ -153,27 +288,66 @@ func (s *Service) RefreshAccessToken(ctx context.Context, refreshTokenString str
 		return "", "", ErrExpiredToken
 	}
 
-	err = s.refreshTokenRepo.RevokeRefreshToken(ctx, refreshTokenString)
-	if err != nil {
-		return "", "", err
-	}
+	var accessToken string
+	var newRefreshToken *refreshtoken.RefreshToken
+
+	// Revoking the old token and creating its replacement commit together, so a failure can't log the session out
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Only one of several concurrent refreshes with the same token gets past this
+		consumed, err := s.refreshTokenRepo.ConsumeRefreshToken(ctx, refreshTokenString)
+		if errors.Is(err, storage.ErrNotFound) {
+			metrics.RefreshTokenReuseDetections.Inc()
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err := s.userRepo.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+
+		if user.LockedAt != nil {
+			return ErrAccountLocked
+		}
 
-	user, err := s.userRepo.GetUserByID(ctx, token.UserID)
+		accessToken, err = s.generateAccessToken(user.ID, s.userClaims(user))
+		if err != nil {
+			return err
+		}
+
+		// The replacement stays in the family, so the session keeps its identity across rotations
+		newRefreshToken, err = s.refreshTokenRepo.ReplaceRefreshToken(ctx, consumed, s.refreshTokenTTL)
+
+		return err
+	})
 	if err != nil {
 		return "", "", err
 	}
 
-	accessToken, err := s.generateAccessToken(user.ID)
-	if err != nil {
-		return "", "", err
+	metrics.RefreshTokenRotations.Inc()
+
+	return accessToken, newRefreshToken.Token, nil
+}
+
+func (s *Service) CheckSigningKey() error {
+	if err := s.signingKey.check(); err != nil {
+		return err
 	}
 
-	newRefreshToken, err := s.refreshTokenRepo.CreateRefreshToken(ctx, user.ID, s.refreshTokenTTL)
+	tokenString, err := s.generateAccessToken(uuid.Nil, nil)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
-	return accessToken, newRefreshToken.Token, nil
+	_, err = s.ValidateToken(tokenString)
+
+	return err
+}
+
+func (s *Service) JWKS() JWKS {
+	return s.signingKey.JWKS()
 }
 
 func (s *Service) AccessTokenTTL() time.Duration {
@@ -0,0 +1,226 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+const concurrentRequests = 10
+
+var backends = []struct {
+	name       string
+	newService func(t *testing.T) *auth.Service
+}{
+	{"Memory", func(t *testing.T) *auth.Service {
+		return newService(storage.NopTransactor{}, user.NewMemoryUserRepository(), refreshtoken.NewMemoryRefreshTokenRepository())
+	}},
+	{"SQLite", func(t *testing.T) *auth.Service {
+		db := databasetest.NewSQLite(t)
+		return newService(database.NewSQLiteTransactor(db), user.NewSQLiteUserRepository(db), refreshtoken.NewSQLiteRefreshTokenRepository(db))
+	}},
+	{"Postgres", func(t *testing.T) *auth.Service {
+		pool := databasetest.NewPool(t)
+		return newService(database.NewPostgresTransactor(pool), user.NewPostgresUserRepository(pool), refreshtoken.NewPostgresRefreshTokenRepository(pool))
+	}},
+}
+
+func newService(tx storage.Transactor, userRepo user.Repository, refreshTokenRepo refreshtoken.Repository) *auth.Service {
+	return auth.NewService(tx, userRepo, refreshTokenRepo, auth.NewHMACKey("test-secret"), "http://localhost", "test", time.Minute, time.Hour, nil)
+}
+
+func TestConcurrentRegisterWithSameEmail(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			service := backend.newService(t)
+			email := uuid.NewString() + "@example.com"
+
+			errs := runConcurrently(func() error {
+				_, _, _, err := service.Register(context.Background(), email, "password", time.Hour)
+				return err
+			})
+
+			registered := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					registered++
+				case !errors.Is(err, auth.ErrEmailInUse):
+					t.Errorf("Register error = %v, want auth.ErrEmailInUse", err)
+				}
+			}
+			if registered != 1 {
+				t.Errorf("%d concurrent registrations succeeded, want 1", registered)
+			}
+		})
+	}
+}
+
+func TestConcurrentRefreshWithSameToken(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			service := backend.newService(t)
+			ctx := context.Background()
+
+			_, _, refreshToken, err := service.Register(ctx, uuid.NewString()+"@example.com", "password", time.Hour)
+			if err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			errs := runConcurrently(func() error {
+				_, _, err := service.RefreshAccessToken(ctx, refreshToken)
+				return err
+			})
+
+			refreshed := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					refreshed++
+				case !errors.Is(err, auth.ErrInvalidToken):
+					t.Errorf("RefreshAccessToken error = %v, want auth.ErrInvalidToken", err)
+				}
+			}
+			if refreshed != 1 {
+				t.Errorf("%d concurrent refreshes succeeded, want 1", refreshed)
+			}
+		})
+	}
+}
+
+// runConcurrently starts fn concurrentRequests times at once and collects the errors
+func runConcurrently(fn func() error) []error {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, concurrentRequests)
+
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			<-start
+			errs[i] = fn()
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+func TestMetadataClaims(t *testing.T) {
+	ctx := context.Background()
+	userRepo := user.NewMemoryUserRepository()
+	service := auth.NewService(storage.NopTransactor{}, userRepo, refreshtoken.NewMemoryRefreshTokenRepository(), auth.NewHMACKey("test-secret"), "http://localhost", "test", time.Minute, time.Hour,
+		[]string{"app_metadata.plan", "app_metadata.missing", "user_metadata.theme"})
+
+	userID, _, refreshToken, err := service.Register(ctx, uuid.NewString()+"@example.com", "password", time.Hour)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if err := userRepo.UpdateAppMetadata(ctx, userID, json.RawMessage(`{"plan":"pro","internal":true}`)); err != nil {
+		t.Fatalf("UpdateAppMetadata: %v", err)
+	}
+	if err := userRepo.UpdateProfile(ctx, userID, user.Profile{UserMetadata: json.RawMessage(`{"theme":"dark"}`)}); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+
+	accessToken, _, err := service.RefreshAccessToken(ctx, refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+
+	claims, err := service.ValidateToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	if got, want := claims["app_metadata"], map[string]any{"plan": "pro"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("app_metadata claim = %v, want %v", got, want)
+	}
+	if got, want := claims["user_metadata"], map[string]any{"theme": "dark"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("user_metadata claim = %v, want %v", got, want)
+	}
+}
+
+func TestValidateTokenSignedWithPreviousKey(t *testing.T) {
+	oldKey := newEd25519Key(t)
+	newKey := newEd25519Key(t)
+
+	tests := []struct {
+		name     string
+		previous auth.SigningKey
+		current  auth.SigningKey
+		jwksKeys int
+	}{
+		{"HMAC", auth.NewHMACKey("old-secret"), auth.NewHMACKey("new-secret"), 0},
+		{"EdDSA", oldKey, newKey, 2},
+		{"HMAC to EdDSA", auth.NewHMACKey("old-secret"), newKey, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			userRepo := user.NewMemoryUserRepository()
+			refreshTokenRepo := refreshtoken.NewMemoryRefreshTokenRepository()
+
+			before := auth.NewService(storage.NopTransactor{}, userRepo, refreshTokenRepo, tt.previous, "http://localhost", "test", time.Minute, time.Hour, nil)
+			_, accessToken, _, err := before.Register(ctx, uuid.NewString()+"@example.com", "password", time.Hour)
+			if err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			rotated := auth.NewService(storage.NopTransactor{}, userRepo, refreshTokenRepo, tt.current.WithPrevious(tt.previous), "http://localhost", "test", time.Minute, time.Hour, nil)
+			if _, err := rotated.ValidateToken(accessToken); err != nil {
+				t.Errorf("ValidateToken with previous key: %v", err)
+			}
+			if got := len(rotated.JWKS().Keys); got != tt.jwksKeys {
+				t.Errorf("JWKS has %d keys, want %d", got, tt.jwksKeys)
+			}
+
+			withoutPrevious := auth.NewService(storage.NopTransactor{}, userRepo, refreshTokenRepo, tt.current, "http://localhost", "test", time.Minute, time.Hour, nil)
+			if _, err := withoutPrevious.ValidateToken(accessToken); !errors.Is(err, auth.ErrInvalidToken) {
+				t.Errorf("ValidateToken without previous key error = %v, want auth.ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func newEd25519Key(t *testing.T) auth.SigningKey {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+
+	key, err := auth.ParseEd25519Key(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseEd25519Key: %v", err)
+	}
+
+	return key
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+)
+
+// Session is a signed-in device. Its ID is that of its current refresh token,
+// so it changes whenever the session is refreshed.
+type Session struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Current   bool
+}
+
+type SessionService struct {
+	refreshTokenRepo refreshtoken.Repository
+}
+
+func NewSessionService(refreshTokenRepo refreshtoken.Repository) *SessionService {
+	return &SessionService{
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// List returns the user's active sessions, newest first, marking the one
+// refreshTokenString belongs to as current.
+func (s *SessionService) List(ctx context.Context, userID uuid.UUID, refreshTokenString string) (_ []Session, err error) {
+	defer trace(&ctx, "auth.SessionService.List", &err)()
+
+	tokens, err := s.refreshTokenRepo.ListUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var currentID uuid.UUID
+	if current := s.current(ctx, userID, refreshTokenString); current != nil {
+		currentID = current.ID
+	}
+
+	now := time.Now()
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Revoked || !token.ExpiresAt.After(now) {
+			continue
+		}
+
+		sessions = append(sessions, Session{
+			ID:        token.ID,
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			Current:   token.ID == currentID,
+		})
+	}
+
+	return sessions, nil
+}
+
+// Revoke signs the session out. Its access token stays valid until it expires.
+func (s *SessionService) Revoke(ctx context.Context, userID, id uuid.UUID) (err error) {
+	defer trace(&ctx, "auth.SessionService.Revoke", &err)()
+
+	return s.refreshTokenRepo.RevokeUserRefreshToken(ctx, userID, id)
+}
+
+// RevokeOthers signs out every session of the user but the one
+// refreshTokenString belongs to, or all of them without it.
+func (s *SessionService) RevokeOthers(ctx context.Context, userID uuid.UUID, refreshTokenString string) (err error) {
+	defer trace(&ctx, "auth.SessionService.RevokeOthers", &err)()
+
+	var exceptFamilyID *uuid.UUID
+	if current := s.current(ctx, userID, refreshTokenString); current != nil {
+		exceptFamilyID = &current.FamilyID
+	}
+
+	return s.refreshTokenRepo.RevokeUserRefreshTokens(ctx, userID, exceptFamilyID)
+}
+
+func (s *SessionService) current(ctx context.Context, userID uuid.UUID, refreshTokenString string) *refreshtoken.RefreshToken {
+	if refreshTokenString == "" {
+		return nil
+	}
+
+	token, err := s.refreshTokenRepo.GetRefreshToken(ctx, refreshTokenString)
+	// An unknown or foreign token just means no session is current
+	if err != nil || token.UserID != userID {
+		return nil
+	}
+
+	return token
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionHandler struct {
+	service *SessionService
+}
+
+func NewSessionHandler(service *SessionService) *SessionHandler {
+	return &SessionHandler{
+		service: service,
+	}
+}
+
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+func (h *SessionHandler) ListSessions(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	sessions, err := h.service.List(c.Request().Context(), userID, refreshTokenCookie(c))
+	if err != nil {
+		return err
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:        session.ID.String(),
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.Current,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"sessions": response,
+	})
+}
+
+func (h *SessionHandler) RevokeSession(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	err = h.service.Revoke(c.Request().Context(), userID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Session revoked successfully"})
+}
+
+func (h *SessionHandler) RevokeOtherSessions(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	if err := h.service.RevokeOthers(c.Request().Context(), userID, refreshTokenCookie(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Other sessions revoked successfully"})
+}
+
+func refreshTokenCookie(c echo.Context) string {
+	if cookie, err := c.Cookie("refresh_token"); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
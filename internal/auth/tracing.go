@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/joacolabadie/go-auth-template-v2/internal/auth")
+
+// trace starts a span named name as a child of *ctx and replaces *ctx with the
+// span's context. The returned function ends the span with the error in *err,
+// so deferring it records the method's named error result:
+//
+//	defer trace(&ctx, "auth.Service.Logout", &err)()
+func trace(ctx *context.Context, name string, err *error) func() {
+	var span oteltrace.Span
+	*ctx, span = tracer.Start(*ctx, name)
+
+	return func() { endSpan(span, *err) }
+}
+
+// traceAttempt is trace for the methods behind an auth flow, which also count
+// the attempt's outcome
+func traceAttempt(ctx *context.Context, name, flow string, err *error) func() {
+	end := trace(ctx, name, err)
+
+	return func() {
+		recordAttempt(flow, *err)
+		end()
+	}
+}
+
+func endSpan(span oteltrace.Span, err error) {
+	// Asking for a second factor is a normal outcome, not a failure
+	var mfaErr *MFARequiredError
+	if err != nil && !errors.As(err, &mfaErr) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRecordsTheNamedErrorResult(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	child := func(ctx context.Context) (err error) {
+		defer trace(&ctx, "child", &err)()
+
+		return errors.New("boom")
+	}
+	parent := func(ctx context.Context) (err error) {
+		defer trace(&ctx, "parent", &err)()
+
+		// The failure is handled here, so only the child records it
+		_ = child(ctx)
+
+		return nil
+	}
+
+	if err := parent(context.Background()); err != nil {
+		t.Fatalf("parent: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+
+	childSpan, parentSpan := spans[0], spans[1]
+	if childSpan.Parent().SpanID() != parentSpan.SpanContext().SpanID() {
+		t.Errorf("child span isn't a child of the parent span")
+	}
+	if childSpan.Status().Code != codes.Error {
+		t.Errorf("child span status = %v, want an error", childSpan.Status().Code)
+	}
+	if parentSpan.Status().Code == codes.Error {
+		t.Errorf("parent span status = %v, want no error", parentSpan.Status().Code)
+	}
+}
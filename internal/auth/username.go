@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+type UsernameService struct {
+	tx       storage.Transactor
+	userRepo user.Repository
+	cooldown time.Duration
+}
+
+func NewUsernameService(tx storage.Transactor, userRepo user.Repository, cooldown time.Duration) *UsernameService {
+	return &UsernameService{
+		tx:       tx,
+		userRepo: userRepo,
+		cooldown: cooldown,
+	}
+}
+
+// Availability reports whether username could be claimed right now, returning
+// ErrUsernameInvalid, ErrUsernameReserved or ErrUsernameTaken when it can't.
+func (s *UsernameService) Availability(ctx context.Context, username string) (err error) {
+	defer trace(&ctx, "auth.UsernameService.Availability", &err)()
+
+	if err := user.ValidateUsername(username); err != nil {
+		return err
+	}
+
+	return s.ensureUsernameFree(ctx, uuid.Nil, username)
+}
+
+// ChangeUsername sets the user's username and returns when it may next be
+// changed. Setting the first username isn't subject to the cooldown.
+func (s *UsernameService) ChangeUsername(ctx context.Context, userID uuid.UUID, username string) (_ time.Time, err error) {
+	defer trace(&ctx, "auth.UsernameService.ChangeUsername", &err)()
+
+	if err := user.ValidateUsername(username); err != nil {
+		return time.Time{}, err
+	}
+
+	// The user stays locked until the change is written, so concurrent changes
+	// can't all pass the cooldown check
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		u, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if u.UsernameChangedAt != nil && time.Now().Before(u.UsernameChangedAt.Add(s.cooldown)) {
+			return ErrUsernameCooldown
+		}
+
+		if err := s.ensureUsernameFree(ctx, userID, username); err != nil {
+			return err
+		}
+
+		return s.userRepo.UpdateUsername(ctx, userID, username)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().Add(s.cooldown), nil
+}
+
+// Resolve finds the user with the username. When the name was given up, it
+// returns its previous owner and moved set to true so callers can redirect.
+func (s *UsernameService) Resolve(ctx context.Context, username string) (_ *user.User, moved bool, err error) {
+	defer trace(&ctx, "auth.UsernameService.Resolve", &err)()
+
+	u, err := s.userRepo.GetUserByUsername(ctx, username)
+	if errors.Is(err, storage.ErrNotFound) {
+		u, err = s.userRepo.GetUserByPreviousUsername(ctx, username)
+		moved = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Accounts that are locked or on their way out aren't listed
+	if u.LockedAt != nil || u.DeletionScheduledAt != nil || u.Username == nil {
+		return nil, false, storage.ErrNotFound
+	}
+
+	return u, moved, nil
+}
+
+// ensureUsernameFree checks that neither another user's current nor previous
+// username has the same key. The repository enforces the same, this just
+// answers before the write.
+func (s *UsernameService) ensureUsernameFree(ctx context.Context, userID uuid.UUID, username string) error {
+	for _, lookup := range []func(context.Context, string) (*user.User, error){
+		s.userRepo.GetUserByUsername,
+		s.userRepo.GetUserByPreviousUsername,
+	} {
+		u, err := lookup(ctx, username)
+		if err == nil && u.ID != userID {
+			return ErrUsernameTaken
+		}
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type UsernameHandler struct {
+	service *UsernameService
+}
+
+func NewUsernameHandler(service *UsernameService) *UsernameHandler {
+	return &UsernameHandler{
+		service: service,
+	}
+}
+
+type UsernameAvailabilityRequest struct {
+	Username string `query:"username" validate:"required"`
+}
+
+type UsernameAvailabilityResponse struct {
+	Username  string `json:"username"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (h *UsernameHandler) CheckAvailability(c echo.Context) error {
+	var req UsernameAvailabilityRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	response := UsernameAvailabilityResponse{
+		Username:  user.NormalizeUsername(req.Username),
+		Available: true,
+	}
+
+	err := h.service.Availability(c.Request().Context(), req.Username)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrUsernameInvalid), errors.Is(err, ErrUsernameReserved), errors.Is(err, ErrUsernameTaken):
+		response.Available = false
+		response.Reason = err.Error()
+	default:
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+type ChangeUsernameRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+func (h *UsernameHandler) ChangeUsername(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req ChangeUsernameRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	nextChangeAt, err := h.service.ChangeUsername(c.Request().Context(), userID, req.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"username":       user.NormalizeUsername(req.Username),
+		"next_change_at": nextChangeAt.UTC().Truncate(time.Second),
+	})
+}
+
+type PublicUserResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+func (h *UsernameHandler) GetUser(c echo.Context) error {
+	username := c.Param("username")
+
+	u, moved, err := h.service.Resolve(c.Request().Context(), username)
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Links to a previous username keep working by pointing at the current one
+	if moved {
+		return c.Redirect(http.StatusMovedPermanently, "/api/users/"+url.PathEscape(*u.Username))
+	}
+
+	return c.JSON(http.StatusOK, PublicUserResponse{
+		ID:       u.ID.String(),
+		Username: *u.Username,
+	})
+}
@@ -0,0 +1,84 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+// The memory repository is left out since it has no transactions to lock in
+var usernameBackends = []struct {
+	name    string
+	newRepo func(t *testing.T) (storage.Transactor, user.Repository)
+}{
+	{"SQLite", func(t *testing.T) (storage.Transactor, user.Repository) {
+		db := databasetest.NewSQLite(t)
+		return database.NewSQLiteTransactor(db), user.NewSQLiteUserRepository(db)
+	}},
+	{"Postgres", func(t *testing.T) (storage.Transactor, user.Repository) {
+		pool := databasetest.NewPool(t)
+		return database.NewPostgresTransactor(pool), user.NewPostgresUserRepository(pool)
+	}},
+}
+
+func TestConcurrentUsernameChangesRespectCooldown(t *testing.T) {
+	for _, backend := range usernameBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			tx, repo := backend.newRepo(t)
+			service := auth.NewUsernameService(tx, repo, time.Hour)
+			ctx := context.Background()
+
+			userID, err := repo.CreateUser(ctx, uuid.NewString()+"@example.com", "hash")
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			// Hex digits are mapped to letters since 0 and 1 fold into o and l in the username key
+			prefix := "racer_" + strings.Map(func(r rune) rune {
+				if r >= '0' && r <= '9' {
+					return 'g' + r - '0'
+				}
+				return r
+			}, strings.ReplaceAll(uuid.NewString(), "-", "")[:12])
+
+			// The first username isn't subject to the cooldown, every change after it is
+			var next atomic.Int32
+			errs := runConcurrently(func() error {
+				_, err := service.ChangeUsername(ctx, userID, fmt.Sprintf("%s_%d", prefix, next.Add(1)))
+				return err
+			})
+
+			changed := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					changed++
+				case !errors.Is(err, auth.ErrUsernameCooldown):
+					t.Errorf("ChangeUsername error = %v, want auth.ErrUsernameCooldown", err)
+				}
+			}
+			if changed != 1 {
+				t.Errorf("%d concurrent username changes succeeded, want 1", changed)
+			}
+
+			history, err := repo.ListPreviousUsernames(ctx, userID)
+			if err != nil {
+				t.Fatalf("ListPreviousUsernames: %v", err)
+			}
+			if len(history) != 0 {
+				t.Errorf("username history has %d entries after setting the first username, want none: %+v", len(history), history)
+			}
+		})
+	}
+}
@@ -4,26 +4,103 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type ServerConfig struct {
-	Port string
+	Port      string
+	PublicURL string
+	// MetricsAddr is where /metrics is served, apart from the API so it isn't
+	// exposed publicly. Empty disables it.
+	MetricsAddr     string
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
 }
 
 type DatabaseConfig struct {
+	// Driver is postgres or sqlite. For sqlite the connection string is the
+	// path of the database file.
+	Driver           string
 	ConnectionString string
 	MaxConns         int32
 	MinConns         int32
 	MaxConnLifetime  time.Duration
+	AutoMigrate      bool
 }
 
 type JWTConfig struct {
-	Secret          string
+	Secret         string
+	PrivateKeyFile string
+	// PreviousSecret or PreviousPrivateKeyFile hold the key in use before the
+	// last rotation, whose access tokens are accepted until they expire
+	PreviousSecret         string
+	PreviousPrivateKeyFile string
+	Issuer                 string
+	Audience               string
+	// MetadataClaims lists the metadata keys copied into access tokens, each
+	// as app_metadata.<key> or user_metadata.<key>
+	MetadataClaims  []string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	// IntrospectionClients maps the client IDs allowed to call the
+	// introspection endpoint to their secrets
+	IntrospectionClients map[string]string
+}
+
+type AuthConfig struct {
+	MagicLinkTTL   time.Duration
+	OTPTTL         time.Duration
+	OTPMaxAttempts int
+	EmailChangeTTL time.Duration
+
+	// EmailProviderRules treats addresses that well-known providers deliver to
+	// the same mailbox, like j.doe+tag@gmail.com and jdoe@gmail.com, as one.
+	EmailProviderRules bool
+
+	UsernameChangeCooldown     time.Duration
+	AccountDeletionGracePeriod time.Duration
+
+	UserMetadataMaxBytes int
+	AppMetadataMaxBytes  int
+	// UserMetadataSchema is the path of an OpenAPI schema object, in JSON,
+	// user_metadata must match
+	UserMetadataSchema string
+}
+
+type MaintenanceConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+type MailerConfig struct {
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type SMSConfig struct {
+	Provider  string
+	LogFile   string
+	HTTPURL   string
+	HTTPToken string
+}
+
+type TracingConfig struct {
+	Exporter    string
+	ServiceName string
+	SampleRatio float64
+}
+
+type LogConfig struct {
+	Level string
+	// MessageBodies makes the log mailer and SMS sender log message bodies,
+	// which hold sign-in links and codes, at debug level. Development only.
+	MessageBodies bool
 }
 
 type AppConfig struct {
@@ -31,6 +108,12 @@ type AppConfig struct {
 	Server      ServerConfig
 	Database    DatabaseConfig
 	JWT         JWTConfig
+	Auth        AuthConfig
+	Mailer      MailerConfig
+	SMS         SMSConfig
+	Maintenance MaintenanceConfig
+	Tracing     TracingConfig
+	Log         LogConfig
 }
 
 func loadEnv() error {
@@ -39,7 +122,7 @@ func loadEnv() error {
 		return fmt.Errorf("error loading .env file: %w", err)
 	}
 
-	requiredVars := []string{"ENVIRONMENT", "DATABASE_URL", "JWT_SECRET"}
+	requiredVars := []string{"ENVIRONMENT", "DATABASE_URL"}
 	for _, v := range requiredVars {
 		if os.Getenv(v) == "" {
 			return fmt.Errorf("required environment variable %s is not set", v)
@@ -49,6 +132,15 @@ func loadEnv() error {
 	return nil
 }
 
+func getEnvString(key string, fallback string) string {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	return val
+}
+
 func getEnvInt32(key string, fallback int32) int32 {
 	valStr := os.Getenv(key)
 	if valStr == "" {
@@ -63,6 +155,46 @@ func getEnvInt32(key string, fallback int32) int32 {
 	return int32(val)
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	valStr := os.Getenv(key)
+	if valStr == "" {
+		return fallback
+	}
+
+	val, err := strconv.ParseBool(valStr)
+	if err != nil {
+		return fallback
+	}
+
+	return val
+}
+
+func getEnvFloat64(key string, fallback float64) float64 {
+	valStr := os.Getenv(key)
+	if valStr == "" {
+		return fallback
+	}
+
+	val, err := strconv.ParseFloat(valStr, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return val
+}
+
+func getEnvList(key string) []string {
+	var list []string
+
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	valStr := os.Getenv(key)
 	if valStr == "" {
@@ -93,20 +225,120 @@ func LoadConfig() (AppConfig, error) {
 	}
 
 	cfg.Server = ServerConfig{
-		Port: port,
+		Port:            port,
+		PublicURL:       getEnvString("PUBLIC_URL", "http://localhost:"+port),
+		MetricsAddr:     getEnvString("METRICS_ADDR", ":9090"),
+		ShutdownDelay:   getEnvDuration("SHUTDOWN_DELAY", 0),
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
 	}
 
 	cfg.Database = DatabaseConfig{
+		Driver:           getEnvString("DB_DRIVER", "postgres"),
 		ConnectionString: os.Getenv("DATABASE_URL"),
 		MaxConns:         getEnvInt32("DB_MAX_CONNS", 10),
 		MinConns:         getEnvInt32("DB_MIN_CONNS", 2),
 		MaxConnLifetime:  getEnvDuration("DB_MAX_CONN_LIFETIME", time.Hour),
+		AutoMigrate:      getEnvBool("DB_AUTO_MIGRATE", false),
 	}
 
 	cfg.JWT = JWTConfig{
-		Secret:          os.Getenv("JWT_SECRET"),
-		AccessTokenTTL:  15 * time.Minute,
-		RefreshTokenTTL: 30 * 24 * time.Hour,
+		Secret:                 os.Getenv("JWT_SECRET"),
+		PrivateKeyFile:         os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		PreviousSecret:         os.Getenv("JWT_PREVIOUS_SECRET"),
+		PreviousPrivateKeyFile: os.Getenv("JWT_PREVIOUS_PRIVATE_KEY_FILE"),
+		Issuer:                 getEnvString("JWT_ISSUER", cfg.Server.PublicURL),
+		Audience:               getEnvString("JWT_AUDIENCE", "go-auth-template-v2"),
+		MetadataClaims:         getEnvList("JWT_METADATA_CLAIMS"),
+		AccessTokenTTL:         15 * time.Minute,
+		RefreshTokenTTL:        30 * 24 * time.Hour,
+
+		IntrospectionClients: map[string]string{},
+	}
+
+	cfg.Auth = AuthConfig{
+		MagicLinkTTL:   getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+		OTPTTL:         getEnvDuration("OTP_TTL", 10*time.Minute),
+		OTPMaxAttempts: int(getEnvInt32("OTP_MAX_ATTEMPTS", 5)),
+		EmailChangeTTL: getEnvDuration("EMAIL_CHANGE_TTL", 24*time.Hour),
+
+		EmailProviderRules: getEnvBool("EMAIL_PROVIDER_RULES", false),
+
+		UsernameChangeCooldown:     getEnvDuration("USERNAME_CHANGE_COOLDOWN", 30*24*time.Hour),
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+
+		UserMetadataMaxBytes: int(getEnvInt32("USER_METADATA_MAX_BYTES", 4096)),
+		AppMetadataMaxBytes:  int(getEnvInt32("APP_METADATA_MAX_BYTES", 16384)),
+		UserMetadataSchema:   os.Getenv("USER_METADATA_SCHEMA"),
+	}
+
+	cfg.Maintenance = MaintenanceConfig{
+		Interval:  getEnvDuration("MAINTENANCE_INTERVAL", 15*time.Minute),
+		BatchSize: int(getEnvInt32("MAINTENANCE_BATCH_SIZE", 1000)),
+	}
+
+	cfg.Tracing = TracingConfig{
+		Exporter:    getEnvString("TRACING_EXPORTER", "none"),
+		ServiceName: getEnvString("OTEL_SERVICE_NAME", "go-auth-template-v2"),
+		SampleRatio: getEnvFloat64("TRACING_SAMPLE_RATIO", 1),
+	}
+
+	cfg.Log = LogConfig{
+		Level:         getEnvString("LOG_LEVEL", "info"),
+		MessageBodies: getEnvBool("LOG_MESSAGE_BODIES", false),
+	}
+
+	cfg.Mailer = MailerConfig{
+		From:         getEnvString("MAIL_FROM", "no-reply@localhost"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvString("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+
+	cfg.SMS = SMSConfig{
+		Provider:  getEnvString("SMS_PROVIDER", "log"),
+		LogFile:   os.Getenv("SMS_LOG_FILE"),
+		HTTPURL:   os.Getenv("SMS_HTTP_URL"),
+		HTTPToken: os.Getenv("SMS_HTTP_TOKEN"),
+	}
+
+	if cfg.Database.Driver != "postgres" && cfg.Database.Driver != "sqlite" {
+		return AppConfig{}, fmt.Errorf("unsupported DB_DRIVER %q (expected postgres or sqlite)", cfg.Database.Driver)
+	}
+
+	if cfg.JWT.Secret == "" && cfg.JWT.PrivateKeyFile == "" {
+		return AppConfig{}, fmt.Errorf("either JWT_SECRET or JWT_PRIVATE_KEY_FILE must be set")
+	}
+
+	for _, claim := range cfg.JWT.MetadataClaims {
+		field, key, _ := strings.Cut(claim, ".")
+		if (field != "app_metadata" && field != "user_metadata") || key == "" {
+			return AppConfig{}, fmt.Errorf("invalid JWT_METADATA_CLAIMS entry %q (expected app_metadata.<key> or user_metadata.<key>)", claim)
+		}
+	}
+
+	for _, client := range getEnvList("INTROSPECTION_CLIENTS") {
+		id, secret, _ := strings.Cut(client, ":")
+		if id == "" || secret == "" {
+			return AppConfig{}, fmt.Errorf("invalid INTROSPECTION_CLIENTS entry (expected <client id>:<secret>)")
+		}
+		cfg.JWT.IntrospectionClients[id] = secret
+	}
+
+	if cfg.Maintenance.Interval <= 0 {
+		return AppConfig{}, fmt.Errorf("MAINTENANCE_INTERVAL must be positive, got %s", cfg.Maintenance.Interval)
+	}
+
+	if cfg.Maintenance.BatchSize <= 0 {
+		return AppConfig{}, fmt.Errorf("MAINTENANCE_BATCH_SIZE must be positive, got %d", cfg.Maintenance.BatchSize)
+	}
+
+	if cfg.Log.MessageBodies && cfg.Environment != "development" {
+		return AppConfig{}, fmt.Errorf("LOG_MESSAGE_BODIES is only allowed when ENVIRONMENT is development")
+	}
+
+	if cfg.SMS.Provider == "http" && cfg.SMS.HTTPURL == "" {
+		return AppConfig{}, fmt.Errorf("SMS_HTTP_URL is required when SMS_PROVIDER is http")
 	}
 
 	return cfg, nil
@@ -0,0 +1,75 @@
+// Package databasetest connects tests to real databases: a throwaway SQLite
+// file, or a Postgres that tests are skipped without unless TEST_DATABASE_URL
+// points at a database they may write to.
+package databasetest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/config"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+)
+
+// NewPool connects to TEST_DATABASE_URL and applies all migrations. Tests
+// share the database, so they must not assume it starts empty.
+func NewPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	connectionString := os.Getenv("TEST_DATABASE_URL")
+	if connectionString == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, connectionString)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := database.NewMigrator(pool)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	return pool
+}
+
+// NewSQLite creates a migrated SQLite database in a temporary directory that
+// is removed when the test ends.
+func NewSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := database.ConnectSQLite(config.DatabaseConfig{
+		ConnectionString: filepath.Join(t.TempDir(), "test.db"),
+		MaxConns:         10,
+		MinConns:         1,
+		MaxConnLifetime:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewSQLiteMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    last_login TIMESTAMP
+);
@@ -0,0 +1,11 @@
+CREATE TABLE magic_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    email TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    nonce_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX magic_links_email_idx ON magic_links (email);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_deletion_scheduled_at_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_scheduled_at,
+    DROP COLUMN IF EXISTS phone_verified,
+    DROP COLUMN IF EXISTS phone_number;
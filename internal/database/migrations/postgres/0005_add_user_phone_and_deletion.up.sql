@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN phone_number TEXT,
+    ADD COLUMN phone_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX users_deletion_scheduled_at_idx ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
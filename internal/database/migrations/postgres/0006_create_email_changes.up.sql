@@ -0,0 +1,15 @@
+CREATE TABLE email_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    cancel_token TEXT NOT NULL UNIQUE,
+    session_token_id UUID,
+    expires_at TIMESTAMPTZ NOT NULL,
+    confirmed_at TIMESTAMP,
+    cancelled_at TIMESTAMP
+);
+
+CREATE INDEX email_changes_user_id_idx ON email_changes (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_email_key_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_key;
@@ -0,0 +1,16 @@
+-- email keeps the address as the user typed it, email_key is what identity and uniqueness are based on
+ALTER TABLE users ADD COLUMN email_key TEXT;
+
+UPDATE users SET email_key = lower(normalize(btrim(email), NFC));
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY email_key HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'some users share an email that differs only in case, list them with "migrate check-emails" and resolve them first';
+    END IF;
+END
+$$;
+
+ALTER TABLE users ALTER COLUMN email_key SET NOT NULL;
+
+CREATE UNIQUE INDEX users_email_key_idx ON users (email_key);
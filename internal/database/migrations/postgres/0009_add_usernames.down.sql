@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS username_history;
+
+DROP INDEX IF EXISTS users_username_key_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS username_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS username_key;
+ALTER TABLE users DROP COLUMN IF EXISTS username;
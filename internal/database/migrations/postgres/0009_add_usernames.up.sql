@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN username TEXT;
+ALTER TABLE users ADD COLUMN username_key TEXT;
+ALTER TABLE users ADD COLUMN username_changed_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX users_username_key_idx ON users (username_key);
+
+-- Names users have moved away from, still held by them so links to the old name keep resolving
+CREATE TABLE username_history (
+    username_key TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX username_history_user_id_idx ON username_history (user_id);
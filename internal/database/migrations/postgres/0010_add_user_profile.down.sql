@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN IF EXISTS app_metadata;
+ALTER TABLE users DROP COLUMN IF EXISTS user_metadata;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
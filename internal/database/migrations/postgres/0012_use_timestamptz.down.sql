@@ -0,0 +1,11 @@
+ALTER TABLE otp_challenges
+    ALTER COLUMN consumed_at TYPE TIMESTAMP USING consumed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE email_changes
+    ALTER COLUMN cancelled_at TYPE TIMESTAMP USING cancelled_at AT TIME ZONE 'UTC',
+    ALTER COLUMN confirmed_at TYPE TIMESTAMP USING confirmed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE users
+    ALTER COLUMN locked_at TYPE TIMESTAMP USING locked_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deletion_scheduled_at TYPE TIMESTAMP USING deletion_scheduled_at AT TIME ZONE 'UTC',
+    ALTER COLUMN last_login TYPE TIMESTAMP USING last_login AT TIME ZONE 'UTC';
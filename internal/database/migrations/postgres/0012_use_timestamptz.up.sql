@@ -0,0 +1,12 @@
+-- These columns were written with the server's wall clock, which is taken to be UTC
+ALTER TABLE users
+    ALTER COLUMN last_login TYPE TIMESTAMPTZ USING last_login AT TIME ZONE 'UTC',
+    ALTER COLUMN deletion_scheduled_at TYPE TIMESTAMPTZ USING deletion_scheduled_at AT TIME ZONE 'UTC',
+    ALTER COLUMN locked_at TYPE TIMESTAMPTZ USING locked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE email_changes
+    ALTER COLUMN confirmed_at TYPE TIMESTAMPTZ USING confirmed_at AT TIME ZONE 'UTC',
+    ALTER COLUMN cancelled_at TYPE TIMESTAMPTZ USING cancelled_at AT TIME ZONE 'UTC';
+
+ALTER TABLE otp_challenges
+    ALTER COLUMN consumed_at TYPE TIMESTAMPTZ USING consumed_at AT TIME ZONE 'UTC';
@@ -0,0 +1,3 @@
+ALTER TABLE email_changes RENAME COLUMN session_family_id TO session_token_id;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
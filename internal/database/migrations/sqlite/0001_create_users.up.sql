@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    last_login TIMESTAMP
+);
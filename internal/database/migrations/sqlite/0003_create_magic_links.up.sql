@@ -0,0 +1,11 @@
+CREATE TABLE magic_links (
+    id TEXT PRIMARY KEY NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    email TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    nonce_hash TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX magic_links_email_idx ON magic_links (email);
@@ -0,0 +1,15 @@
+CREATE TABLE email_changes (
+    id TEXT PRIMARY KEY NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    cancel_token TEXT NOT NULL UNIQUE,
+    session_token_id TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    cancelled_at TIMESTAMP
+);
+
+CREATE INDEX email_changes_user_id_idx ON email_changes (user_id);
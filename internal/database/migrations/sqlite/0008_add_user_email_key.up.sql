@@ -0,0 +1,7 @@
+-- email keeps the address as the user typed it, email_key is what identity and uniqueness are based on.
+-- lower() only folds ASCII here, "migrate check-emails --apply" recomputes the keys in Go.
+ALTER TABLE users ADD COLUMN email_key TEXT;
+
+UPDATE users SET email_key = lower(trim(email));
+
+CREATE UNIQUE INDEX users_email_key_idx ON users (email_key);
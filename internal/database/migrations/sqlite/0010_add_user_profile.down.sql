@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN app_metadata;
+ALTER TABLE users DROP COLUMN user_metadata;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN display_name;
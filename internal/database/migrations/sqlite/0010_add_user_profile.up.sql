@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN display_name TEXT;
+ALTER TABLE users ADD COLUMN avatar_url TEXT;
+ALTER TABLE users ADD COLUMN locale TEXT;
+ALTER TABLE users ADD COLUMN timezone TEXT;
+
+-- user_metadata is editable by the user, app_metadata only by operators
+ALTER TABLE users ADD COLUMN user_metadata TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN app_metadata TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,7 @@
+-- Every token rotated from the same login shares the family of the first, so a session keeps one ID
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT;
+
+UPDATE refresh_tokens SET family_id = id;
+
+-- The session an email change came from is now identified by its family, existing values already are
+ALTER TABLE email_changes RENAME COLUMN session_token_id TO session_family_id;
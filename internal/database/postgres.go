@@ -16,6 +16,7 @@ func ConnectDatabase(cfg config.DatabaseConfig) (*pgxpool.Pool, error) {
 	poolConfig.MaxConns = cfg.MaxConns
 	poolConfig.MinConns = cfg.MinConns
 	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.ConnConfig.Tracer = QueryTracer{}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// Every SQLite connection enforces foreign keys, waits for locks instead of
+// failing right away, takes the write lock when a transaction begins so two
+// writers can't deadlock, and writes times in a format that sorts as text.
+// Repositories store times in UTC so that text order is time order.
+var sqliteParams = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_time_format=sqlite",
+	"_txlock=immediate",
+}
+
+// ConnectSQLite opens the database file named by the connection string,
+// creating it if needed
+func ConnectSQLite(cfg config.DatabaseConfig) (*sql.DB, error) {
+	dsn := cfg.ConnectionString
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += strings.Join(sqliteParams, "&")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(int(cfg.MaxConns))
+	db.SetMaxIdleConns(int(cfg.MinConns))
+	db.SetConnMaxLifetime(cfg.MaxConnLifetime)
+
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
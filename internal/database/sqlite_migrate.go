@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type SQLiteMigrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewSQLiteMigrator(db *sql.DB) (*SQLiteMigrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteMigrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// withLock runs fn in a single transaction. SQLite has no advisory locks, but
+// transactions take the database write lock up front, which serializes
+// migrators just the same.
+func (m *SQLiteMigrator) withLock(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+		)
+	`
+
+	if _, err := tx.ExecContext(ctx, q); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *SQLiteMigrator) applied(ctx context.Context, tx *sql.Tx) (map[int64]appliedMigration, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// Up applies every pending migration in one transaction, so a failure leaves
+// the schema exactly as it was.
+func (m *SQLiteMigrator) Up(ctx context.Context) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(tx *sql.Tx) error {
+		applied, err := m.applied(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.UpSQL); err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			q := `
+				INSERT INTO schema_migrations (version, name, checksum)
+				VALUES (?, ?, ?)
+			`
+
+			if _, err := tx.ExecContext(ctx, q, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (m *SQLiteMigrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(tx *sql.Tx) error {
+		applied, err := m.applied(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.DownSQL == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.DownSQL); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version); err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (m *SQLiteMigrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.withLock(ctx, func(tx *sql.Tx) error {
+		applied, err := m.applied(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+
+		statuses = migrationStatuses(m.migrations, applied)
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+func (m *SQLiteMigrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+
+	q := `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+
+	err := m.db.QueryRowContext(ctx, q).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (m *SQLiteMigrator) LatestVersion() int64 {
+	return latestVersion(m.migrations)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type pgTxKey struct{}
+
+type sqliteTxKey struct{}
+
+// PostgresQuerier is satisfied by both *pgxpool.Pool and pgx.Tx. Begin on a
+// pgx.Tx opens a savepoint.
+type PostgresQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// PostgresConn returns the transaction carried by ctx, or pool outside of one.
+func PostgresConn(ctx context.Context, pool *pgxpool.Pool) PostgresQuerier {
+	if tx, ok := ctx.Value(pgTxKey{}).(pgx.Tx); ok {
+		return tx
+	}
+
+	return pool
+}
+
+type PostgresTransactor struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresTransactor(pool *pgxpool.Pool) *PostgresTransactor {
+	return &PostgresTransactor{pool: pool}
+}
+
+func (t *PostgresTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pgTxKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	return pgx.BeginFunc(ctx, t.pool, func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, pgTxKey{}, tx))
+	})
+}
+
+// SQLiteQuerier is satisfied by both *sql.DB and *sql.Tx.
+type SQLiteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLiteConn returns the transaction carried by ctx, or db outside of one.
+func SQLiteConn(ctx context.Context, db *sql.DB) SQLiteQuerier {
+	if tx, ok := ctx.Value(sqliteTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+type SQLiteTransactor struct {
+	db *sql.DB
+}
+
+func NewSQLiteTransactor(db *sql.DB) *SQLiteTransactor {
+	return &SQLiteTransactor{db: db}
+}
+
+func (t *SQLiteTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(sqliteTxKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, sqliteTxKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,21 @@
+package emailchange
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailChange struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UserID          uuid.UUID
+	OldEmail        string
+	NewEmail        string
+	Token           string
+	CancelToken     string
+	SessionFamilyID *uuid.UUID
+	ExpiresAt       time.Time
+	ConfirmedAt     *time.Time
+	CancelledAt     *time.Time
+}
@@ -0,0 +1,193 @@
+package emailchange
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type PostgresEmailChangeRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresEmailChangeRepository(db *pgxpool.Pool) *PostgresEmailChangeRepository {
+	return &PostgresEmailChangeRepository{db: db}
+}
+
+func (r *PostgresEmailChangeRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
+func (r *PostgresEmailChangeRepository) CreateEmailChange(ctx context.Context, userID uuid.UUID, oldEmail, newEmail string, sessionFamilyID *uuid.UUID, ttl time.Duration) (*EmailChange, error) {
+	rawToken := uuid.New().String()
+	rawCancelToken := uuid.New().String()
+
+	change := &EmailChange{
+		UserID:          userID,
+		OldEmail:        oldEmail,
+		NewEmail:        newEmail,
+		Token:           rawToken,
+		CancelToken:     rawCancelToken,
+		SessionFamilyID: sessionFamilyID,
+		ExpiresAt:       time.Now().Add(ttl),
+	}
+
+	q := `
+		INSERT INTO email_changes (user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err := r.conn(ctx).QueryRow(ctx, q,
+		change.UserID,
+		change.OldEmail,
+		change.NewEmail,
+		utils.HashToken(rawToken),
+		utils.HashToken(rawCancelToken),
+		change.SessionFamilyID,
+		change.ExpiresAt,
+	).Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func (r *PostgresEmailChangeRepository) ConfirmEmailChange(ctx context.Context, rawToken string) (*EmailChange, error) {
+	q := `
+		UPDATE email_changes
+		SET confirmed_at = $1
+		WHERE token = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL
+		RETURNING id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+	`
+
+	change, err := scanEmailChange(r.conn(ctx).QueryRow(ctx, q, time.Now(), utils.HashToken(rawToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	change.Token = rawToken
+
+	return change, nil
+}
+
+func (r *PostgresEmailChangeRepository) CancelEmailChange(ctx context.Context, rawCancelToken string) (*EmailChange, error) {
+	q := `
+		UPDATE email_changes
+		SET cancelled_at = $1
+		WHERE cancel_token = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL
+		RETURNING id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+	`
+
+	change, err := scanEmailChange(r.conn(ctx).QueryRow(ctx, q, time.Now(), utils.HashToken(rawCancelToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	change.CancelToken = rawCancelToken
+
+	return change, nil
+}
+
+func (r *PostgresEmailChangeRepository) ListUserEmailChanges(ctx context.Context, userID uuid.UUID) ([]EmailChange, error) {
+	q := `
+		SELECT id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+		FROM email_changes
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []EmailChange
+
+	for rows.Next() {
+		change, err := scanEmailChange(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only hashes are stored, so they are not worth handing back
+		change.Token = ""
+		change.CancelToken = ""
+
+		changes = append(changes, *change)
+	}
+
+	return changes, rows.Err()
+}
+
+// Confirmed changes are kept as the account's email history
+func (r *PostgresEmailChangeRepository) DeleteExpiredEmailChanges(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM email_changes
+		WHERE id IN (
+			SELECT id
+			FROM email_changes
+			WHERE confirmed_at IS NULL AND (cancelled_at IS NOT NULL OR expires_at < $1)
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanEmailChange(row pgx.Row) (*EmailChange, error) {
+	var change EmailChange
+	var sessionFamilyID pgtype.UUID
+	var confirmedAt pgtype.Timestamptz
+	var cancelledAt pgtype.Timestamptz
+
+	err := row.Scan(
+		&change.ID,
+		&change.CreatedAt,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.Token,
+		&change.CancelToken,
+		&sessionFamilyID,
+		&change.ExpiresAt,
+		&confirmedAt,
+		&cancelledAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionFamilyID.Valid {
+		id := uuid.UUID(sessionFamilyID.Bytes)
+		change.SessionFamilyID = &id
+	}
+
+	if confirmedAt.Valid {
+		change.ConfirmedAt = &confirmedAt.Time
+	}
+
+	if cancelledAt.Valid {
+		change.CancelledAt = &cancelledAt.Time
+	}
+
+	return &change, nil
+}
@@ -0,0 +1,16 @@
+package emailchange
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	CreateEmailChange(ctx context.Context, userID uuid.UUID, oldEmail, newEmail string, sessionFamilyID *uuid.UUID, ttl time.Duration) (*EmailChange, error)
+	ConfirmEmailChange(ctx context.Context, tokenString string) (*EmailChange, error)
+	CancelEmailChange(ctx context.Context, cancelTokenString string) (*EmailChange, error)
+	ListUserEmailChanges(ctx context.Context, userID uuid.UUID) ([]EmailChange, error)
+	DeleteExpiredEmailChanges(ctx context.Context, batchSize int) (int64, error)
+}
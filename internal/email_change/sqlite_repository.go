@@ -0,0 +1,200 @@
+package emailchange
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type SQLiteEmailChangeRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteEmailChangeRepository(db *sql.DB) *SQLiteEmailChangeRepository {
+	return &SQLiteEmailChangeRepository{db: db}
+}
+
+func (r *SQLiteEmailChangeRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLiteEmailChangeRepository) CreateEmailChange(ctx context.Context, userID uuid.UUID, oldEmail, newEmail string, sessionFamilyID *uuid.UUID, ttl time.Duration) (*EmailChange, error) {
+	rawToken := uuid.New().String()
+	rawCancelToken := uuid.New().String()
+
+	now := time.Now().UTC()
+
+	change := &EmailChange{
+		ID:              uuid.New(),
+		CreatedAt:       now,
+		UserID:          userID,
+		OldEmail:        oldEmail,
+		NewEmail:        newEmail,
+		Token:           rawToken,
+		CancelToken:     rawCancelToken,
+		SessionFamilyID: sessionFamilyID,
+		ExpiresAt:       now.Add(ttl),
+	}
+
+	var sessionFamilyIDValue uuid.NullUUID
+	if sessionFamilyID != nil {
+		sessionFamilyIDValue = uuid.NullUUID{UUID: *sessionFamilyID, Valid: true}
+	}
+
+	q := `
+		INSERT INTO email_changes (id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q,
+		change.ID,
+		change.CreatedAt,
+		change.UserID,
+		change.OldEmail,
+		change.NewEmail,
+		utils.HashToken(rawToken),
+		utils.HashToken(rawCancelToken),
+		sessionFamilyIDValue,
+		change.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func (r *SQLiteEmailChangeRepository) ConfirmEmailChange(ctx context.Context, rawToken string) (*EmailChange, error) {
+	q := `
+		UPDATE email_changes
+		SET confirmed_at = ?
+		WHERE token = ? AND confirmed_at IS NULL AND cancelled_at IS NULL
+		RETURNING id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+	`
+
+	change, err := scanSQLiteEmailChange(r.conn(ctx).QueryRowContext(ctx, q, time.Now().UTC(), utils.HashToken(rawToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	change.Token = rawToken
+
+	return change, nil
+}
+
+func (r *SQLiteEmailChangeRepository) CancelEmailChange(ctx context.Context, rawCancelToken string) (*EmailChange, error) {
+	q := `
+		UPDATE email_changes
+		SET cancelled_at = ?
+		WHERE cancel_token = ? AND confirmed_at IS NULL AND cancelled_at IS NULL
+		RETURNING id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+	`
+
+	change, err := scanSQLiteEmailChange(r.conn(ctx).QueryRowContext(ctx, q, time.Now().UTC(), utils.HashToken(rawCancelToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	change.CancelToken = rawCancelToken
+
+	return change, nil
+}
+
+func (r *SQLiteEmailChangeRepository) ListUserEmailChanges(ctx context.Context, userID uuid.UUID) ([]EmailChange, error) {
+	q := `
+		SELECT id, created_at, user_id, old_email, new_email, token, cancel_token, session_family_id, expires_at, confirmed_at, cancelled_at
+		FROM email_changes
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []EmailChange
+
+	for rows.Next() {
+		change, err := scanSQLiteEmailChange(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only hashes are stored, so they are not worth handing back
+		change.Token = ""
+		change.CancelToken = ""
+
+		changes = append(changes, *change)
+	}
+
+	return changes, rows.Err()
+}
+
+// Confirmed changes are kept as the account's email history
+func (r *SQLiteEmailChangeRepository) DeleteExpiredEmailChanges(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM email_changes
+		WHERE id IN (
+			SELECT id
+			FROM email_changes
+			WHERE confirmed_at IS NULL AND (cancelled_at IS NOT NULL OR expires_at < ?)
+			LIMIT ?
+		)
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanSQLiteEmailChange(row interface{ Scan(dest ...any) error }) (*EmailChange, error) {
+	var change EmailChange
+	var sessionFamilyID uuid.NullUUID
+	var confirmedAt sql.NullTime
+	var cancelledAt sql.NullTime
+
+	err := row.Scan(
+		&change.ID,
+		&change.CreatedAt,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.Token,
+		&change.CancelToken,
+		&sessionFamilyID,
+		&change.ExpiresAt,
+		&confirmedAt,
+		&cancelledAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionFamilyID.Valid {
+		change.SessionFamilyID = &sessionFamilyID.UUID
+	}
+
+	if confirmedAt.Valid {
+		change.ConfirmedAt = &confirmedAt.Time
+	}
+
+	if cancelledAt.Valid {
+		change.CancelledAt = &cancelledAt.Time
+	}
+
+	return &change, nil
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+// Check is a dependency the server needs to serve traffic. An optional check
+// is reported in the readiness response but doesn't make the server not ready,
+// e.g. the mailer, whose outage only delays emails and shouldn't pull every
+// replica out of the load balancer.
+type Check struct {
+	Name     string
+	Check    CheckFunc
+	Optional bool
+}
+
+type Handler struct {
+	checks       []Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewHandler(timeout time.Duration, checks ...Check) *Handler {
+	return &Handler{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (h *Handler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Optional   bool   `json:"optional,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (h *Handler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+func (h *Handler) Readiness(c echo.Context) error {
+	if h.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, ReadinessResponse{
+			Status: "shutting_down",
+			Checks: map[string]CheckResult{},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.timeout)
+	defer cancel()
+
+	results := make(map[string]CheckResult, len(h.checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range h.checks {
+		wg.Add(1)
+
+		go func(check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Check(ctx)
+
+			result := CheckResult{
+				Status:     "ok",
+				DurationMS: time.Since(start).Milliseconds(),
+				Optional:   check.Optional,
+			}
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+
+	wg.Wait()
+
+	response := ReadinessResponse{
+		Status: "ready",
+		Checks: results,
+	}
+
+	for _, result := range results {
+		if result.Status != "ok" && !result.Optional {
+			response.Status = "not_ready"
+
+			return c.JSON(http.StatusServiceUnavailable, response)
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
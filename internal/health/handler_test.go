@@ -0,0 +1,57 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
+	"github.com/labstack/echo/v4"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name   string
+		checks []health.Check
+		status int
+		want   string
+	}{
+		{"all checks pass", []health.Check{{Name: "database", Check: ok}, {Name: "mailer", Check: ok, Optional: true}}, http.StatusOK, "ready"},
+		{"optional check fails", []health.Check{{Name: "database", Check: ok}, {Name: "mailer", Check: down, Optional: true}}, http.StatusOK, "ready"},
+		{"required check fails", []health.Check{{Name: "database", Check: down}, {Name: "mailer", Check: ok, Optional: true}}, http.StatusServiceUnavailable, "not_ready"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := health.NewHandler(time.Second, tt.checks...)
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)
+
+			if err := handler.Readiness(c); err != nil {
+				t.Fatalf("Readiness: %v", err)
+			}
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			var response health.ReadinessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if response.Status != tt.want {
+				t.Errorf("readiness status = %q, want %q", response.Status, tt.want)
+			}
+			if len(response.Checks) != len(tt.checks) {
+				t.Errorf("reported %d checks, want %d", len(response.Checks), len(tt.checks))
+			}
+		})
+	}
+}
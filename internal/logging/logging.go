@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Attribute keys that are never written out, matched case-insensitively on the
+// full key or its suffix so that e.g. "refresh_token" is caught by "token".
+var sensitiveKeys = []string{
+	"password",
+	"password_hash",
+	"token",
+	"secret",
+	"authorization",
+	"cookie",
+	"nonce",
+	"otp_code",
+}
+
+func Setup(w io.Writer, level string) *slog.Logger {
+	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redact,
+	}))
+
+	slog.SetDefault(logger)
+
+	return logger
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return l
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+
+	for _, sensitive := range sensitiveKeys {
+		if key == sensitive || strings.HasSuffix(key, "_"+sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const loggerKey = "logger"
+
+type contextKey struct{}
+
+// Middleware attaches a request-scoped logger carrying the request ID, which
+// must already be set on the response by echo's RequestID middleware.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			req := c.Request()
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+
+			logger := slog.Default().With(
+				slog.String("request_id", requestID),
+			)
+			setLogger(c, logger)
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			attrs := []any{
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", c.Response().Status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", c.RealIP()),
+			}
+
+			level := slog.LevelInfo
+			if c.Response().Status >= 500 {
+				level = slog.LevelError
+			}
+
+			FromContext(c).Log(req.Context(), level, "Request completed", attrs...)
+
+			return nil
+		}
+	}
+}
+
+// WithUserID adds the authenticated user to the request logger once it is known
+func WithUserID(c echo.Context, userID uuid.UUID) {
+	setLogger(c, FromContext(c).With(slog.String("user_id", userID.String())))
+}
+
+func FromContext(c echo.Context) *slog.Logger {
+	if logger, ok := c.Get(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+func FromRequestContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+func setLogger(c echo.Context, logger *slog.Logger) {
+	c.Set(loggerKey, logger)
+	c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), contextKey{}, logger)))
+}
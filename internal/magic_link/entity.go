@@ -0,0 +1,17 @@
+package magiclink
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MagicLink struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Email     string
+	Token     string
+	NonceHash string
+	ExpiresAt time.Time
+	Used      bool
+}
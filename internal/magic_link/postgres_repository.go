@@ -0,0 +1,104 @@
+package magiclink
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type PostgresMagicLinkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMagicLinkRepository(db *pgxpool.Pool) *PostgresMagicLinkRepository {
+	return &PostgresMagicLinkRepository{db: db}
+}
+
+func (r *PostgresMagicLinkRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
+func (r *PostgresMagicLinkRepository) CreateMagicLink(ctx context.Context, email, nonce string, ttl time.Duration) (*MagicLink, error) {
+	rawToken := uuid.New().String()
+	hashedToken := utils.HashToken(rawToken)
+
+	link := &MagicLink{
+		Email:     email,
+		Token:     rawToken,
+		NonceHash: utils.HashToken(nonce),
+		ExpiresAt: time.Now().Add(ttl),
+		Used:      false,
+	}
+
+	q := `
+		INSERT INTO magic_links (email, token, nonce_hash, expires_at, used)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.conn(ctx).QueryRow(ctx, q, link.Email, hashedToken, link.NonceHash, link.ExpiresAt, link.Used).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (r *PostgresMagicLinkRepository) ConsumeMagicLink(ctx context.Context, rawToken string) (*MagicLink, error) {
+	var link MagicLink
+
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		UPDATE magic_links
+		SET used = true
+		WHERE token = $1 AND used = false
+		RETURNING id, created_at, email, token, nonce_hash, expires_at, used
+	`
+
+	err := r.conn(ctx).QueryRow(ctx, q, hashedToken).Scan(
+		&link.ID,
+		&link.CreatedAt,
+		&link.Email,
+		&link.Token,
+		&link.NonceHash,
+		&link.ExpiresAt,
+		&link.Used,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	link.Token = rawToken
+
+	return &link, nil
+}
+
+func (r *PostgresMagicLinkRepository) DeleteExpiredMagicLinks(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM magic_links
+		WHERE id IN (
+			SELECT id
+			FROM magic_links
+			WHERE used = true OR expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
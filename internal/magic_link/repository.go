@@ -0,0 +1,12 @@
+package magiclink
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	CreateMagicLink(ctx context.Context, email, nonce string, ttl time.Duration) (*MagicLink, error)
+	ConsumeMagicLink(ctx context.Context, tokenString string) (*MagicLink, error)
+	DeleteExpiredMagicLinks(ctx context.Context, batchSize int) (int64, error)
+}
@@ -0,0 +1,106 @@
+package magiclink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type SQLiteMagicLinkRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteMagicLinkRepository(db *sql.DB) *SQLiteMagicLinkRepository {
+	return &SQLiteMagicLinkRepository{db: db}
+}
+
+func (r *SQLiteMagicLinkRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLiteMagicLinkRepository) CreateMagicLink(ctx context.Context, email, nonce string, ttl time.Duration) (*MagicLink, error) {
+	rawToken := uuid.New().String()
+	hashedToken := utils.HashToken(rawToken)
+
+	now := time.Now().UTC()
+
+	link := &MagicLink{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		Email:     email,
+		Token:     rawToken,
+		NonceHash: utils.HashToken(nonce),
+		ExpiresAt: now.Add(ttl),
+		Used:      false,
+	}
+
+	q := `
+		INSERT INTO magic_links (id, created_at, email, token, nonce_hash, expires_at, used)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, link.ID, link.CreatedAt, link.Email, hashedToken, link.NonceHash, link.ExpiresAt, link.Used)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (r *SQLiteMagicLinkRepository) ConsumeMagicLink(ctx context.Context, rawToken string) (*MagicLink, error) {
+	var link MagicLink
+
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		UPDATE magic_links
+		SET used = true
+		WHERE token = ? AND used = false
+		RETURNING id, created_at, email, token, nonce_hash, expires_at, used
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, q, hashedToken).Scan(
+		&link.ID,
+		&link.CreatedAt,
+		&link.Email,
+		&link.Token,
+		&link.NonceHash,
+		&link.ExpiresAt,
+		&link.Used,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	link.Token = rawToken
+
+	return &link, nil
+}
+
+func (r *SQLiteMagicLinkRepository) DeleteExpiredMagicLinks(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM magic_links
+		WHERE id IN (
+			SELECT id
+			FROM magic_links
+			WHERE used = true OR expires_at < ?
+			LIMIT ?
+		)
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+)
+
+// LogMailer logs messages instead of sending them. Bodies carry sign-in links
+// and codes, so they are only logged, at debug level, when logBodies is set.
+type LogMailer struct {
+	from      string
+	logBodies bool
+}
+
+func NewLogMailer(from string, logBodies bool) *LogMailer {
+	return &LogMailer{from: from, logBodies: logBodies}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	logger := logging.FromRequestContext(ctx)
+
+	logger.InfoContext(ctx, "Sending email", "from", m.from, "to", msg.To, "subject", msg.Subject)
+
+	if m.logBodies {
+		logger.DebugContext(ctx, "Email body", "to", msg.To, "body", msg.Body)
+	}
+
+	return nil
+}
+
+func (m *LogMailer) Ping(ctx context.Context) error {
+	return nil
+}
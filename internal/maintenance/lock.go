@@ -0,0 +1,87 @@
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Locker elects the instance that runs maintenance
+type Locker interface {
+	// TryLock returns without waiting. When the lock is taken, release must be
+	// called once the run is over.
+	TryLock(ctx context.Context) (release func(), acquired bool, err error)
+}
+
+// Arbitrary but fixed key so only one instance runs maintenance at a time
+const leaderLockKey int64 = 7_349_218_115
+
+// unlockTimeout bounds releasing the lock once a run is over
+const unlockTimeout = 5 * time.Second
+
+// PostgresLocker holds a session advisory lock, so instances sharing the
+// database take turns
+type PostgresLocker struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresLocker(db *pgxpool.Pool) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+func (l *PostgresLocker) TryLock(ctx context.Context) (func(), bool, error) {
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	release := func() {
+		// Not ctx, which is usually done by now since shutdown ends the run
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+
+		if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", leaderLockKey); err != nil {
+			// The lock belongs to the session, so a connection returned to the pool
+			// would keep every instance from running maintenance. Closing it frees the lock.
+			slog.Error("Releasing the maintenance lock failed, closing its connection", "error", err)
+			conn.Hijack().Close(ctx)
+			return
+		}
+
+		conn.Release()
+	}
+
+	return release, true, nil
+}
+
+// LocalLocker only keeps runs within this process from overlapping. It suits
+// SQLite, where a single process owns the database file.
+type LocalLocker struct {
+	mu sync.Mutex
+}
+
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{}
+}
+
+func (l *LocalLocker) TryLock(ctx context.Context) (func(), bool, error) {
+	if !l.mu.TryLock() {
+		return nil, false, nil
+	}
+
+	return l.mu.Unlock, true, nil
+}
@@ -0,0 +1,166 @@
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type PurgeFunc func(ctx context.Context, batchSize int) (int64, error)
+
+type Task struct {
+	Name  string
+	Purge PurgeFunc
+}
+
+type TaskStats struct {
+	Name         string        `json:"name"`
+	Runs         int64         `json:"runs"`
+	LastRunAt    *time.Time    `json:"last_run_at"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastDeleted  int64         `json:"last_deleted"`
+	TotalDeleted int64         `json:"total_deleted"`
+	LastError    string        `json:"last_error,omitempty"`
+}
+
+type Stats struct {
+	Runs         int64       `json:"runs"`
+	SkippedRuns  int64       `json:"skipped_runs"`
+	LastRunAt    *time.Time  `json:"last_run_at"`
+	LastLeaderAt *time.Time  `json:"last_leader_at"`
+	Tasks        []TaskStats `json:"tasks"`
+}
+
+type Worker struct {
+	lock      Locker
+	interval  time.Duration
+	batchSize int
+	tasks     []Task
+
+	mu         sync.Mutex
+	stats      Stats
+	tasksStats map[string]*TaskStats
+}
+
+func NewWorker(lock Locker, interval time.Duration, batchSize int, tasks ...Task) *Worker {
+	w := &Worker{
+		lock:       lock,
+		interval:   interval,
+		batchSize:  batchSize,
+		tasks:      tasks,
+		tasksStats: make(map[string]*TaskStats, len(tasks)),
+	}
+
+	for _, task := range tasks {
+		w.tasksStats[task.Name] = &TaskStats{Name: task.Name}
+	}
+
+	return w
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Maintenance run failed", "error", err)
+			}
+		}
+	}
+}
+
+// RunOnce runs every task if this instance wins the lock, and returns without
+// doing anything when another instance already holds it.
+func (w *Worker) RunOnce(ctx context.Context) error {
+	release, acquired, err := w.lock.TryLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	w.mu.Lock()
+	w.stats.Runs++
+	w.stats.LastRunAt = &now
+	if !acquired {
+		w.stats.SkippedRuns++
+	} else {
+		w.stats.LastLeaderAt = &now
+	}
+	w.mu.Unlock()
+
+	if !acquired {
+		return nil
+	}
+	defer release()
+
+	for _, task := range w.tasks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		w.runTask(ctx, task)
+	}
+
+	return nil
+}
+
+func (w *Worker) runTask(ctx context.Context, task Task) {
+	start := time.Now()
+
+	var deleted int64
+	var err error
+
+	for {
+		var n int64
+
+		n, err = task.Purge(ctx, w.batchSize)
+		deleted += n
+
+		// A short batch means the table is drained; n == 0 also stops a
+		// misconfigured batch size from looping forever
+		if err != nil || n == 0 || n < int64(w.batchSize) {
+			break
+		}
+	}
+
+	duration := time.Since(start)
+
+	w.mu.Lock()
+	stats := w.tasksStats[task.Name]
+	stats.Runs++
+	stats.LastRunAt = &start
+	stats.LastDuration = duration
+	stats.LastDeleted = deleted
+	stats.TotalDeleted += deleted
+	stats.LastError = ""
+	if err != nil {
+		stats.LastError = err.Error()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Maintenance task failed", "task", task.Name, "deleted", deleted, "error", err)
+	} else if deleted > 0 {
+		slog.Info("Maintenance task completed", "task", task.Name, "deleted", deleted, "duration", duration)
+	}
+}
+
+func (w *Worker) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.Tasks = make([]TaskStats, 0, len(w.tasks))
+	for _, task := range w.tasks {
+		stats.Tasks = append(stats.Tasks, *w.tasksStats[task.Name])
+	}
+
+	return stats
+}
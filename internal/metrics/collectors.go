@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/maintenance"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	emptyAcquireWaitTime *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	return &poolCollector{
+		pool:                 pool,
+		acquiredConns:        prometheus.NewDesc("pgxpool_acquired_conns", "Connections currently acquired from the pool.", nil, nil),
+		idleConns:            prometheus.NewDesc("pgxpool_idle_conns", "Idle connections in the pool.", nil, nil),
+		totalConns:           prometheus.NewDesc("pgxpool_total_conns", "Total connections in the pool.", nil, nil),
+		maxConns:             prometheus.NewDesc("pgxpool_max_conns", "Maximum size of the pool.", nil, nil),
+		acquireCount:         prometheus.NewDesc("pgxpool_acquire_count_total", "Successful connection acquisitions.", nil, nil),
+		acquireDuration:      prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil),
+		emptyAcquireCount:    prometheus.NewDesc("pgxpool_empty_acquire_count_total", "Acquisitions that had to wait for a connection.", nil, nil),
+		emptyAcquireWaitTime: prometheus.NewDesc("pgxpool_empty_acquire_wait_seconds_total", "Total time spent waiting for a connection when the pool was empty.", nil, nil),
+		canceledAcquireCount: prometheus.NewDesc("pgxpool_canceled_acquire_count_total", "Acquisitions canceled by their context.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.emptyAcquireWaitTime
+	ch <- c.canceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireWaitTime, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+type maintenanceCollector struct {
+	worker *maintenance.Worker
+
+	runs         *prometheus.Desc
+	skippedRuns  *prometheus.Desc
+	taskDeleted  *prometheus.Desc
+	taskDuration *prometheus.Desc
+	taskFailing  *prometheus.Desc
+}
+
+func NewMaintenanceCollector(worker *maintenance.Worker) prometheus.Collector {
+	return &maintenanceCollector{
+		worker:       worker,
+		runs:         prometheus.NewDesc("maintenance_runs_total", "Maintenance runs attempted by this instance.", nil, nil),
+		skippedRuns:  prometheus.NewDesc("maintenance_skipped_runs_total", "Maintenance runs skipped because another instance held the lock.", nil, nil),
+		taskDeleted:  prometheus.NewDesc("maintenance_task_deleted_rows_total", "Rows deleted by each maintenance task.", []string{"task"}, nil),
+		taskDuration: prometheus.NewDesc("maintenance_task_last_duration_seconds", "Duration of the last run of each maintenance task.", []string{"task"}, nil),
+		taskFailing:  prometheus.NewDesc("maintenance_task_failing", "Whether the last run of each maintenance task failed.", []string{"task"}, nil),
+	}
+}
+
+func (c *maintenanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.runs
+	ch <- c.skippedRuns
+	ch <- c.taskDeleted
+	ch <- c.taskDuration
+	ch <- c.taskFailing
+}
+
+func (c *maintenanceCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.worker.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.runs, prometheus.CounterValue, float64(stats.Runs))
+	ch <- prometheus.MustNewConstMetric(c.skippedRuns, prometheus.CounterValue, float64(stats.SkippedRuns))
+
+	for _, task := range stats.Tasks {
+		failing := 0.0
+		if task.LastError != "" {
+			failing = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.taskDeleted, prometheus.CounterValue, float64(task.TotalDeleted), task.Name)
+		ch <- prometheus.MustNewConstMetric(c.taskDuration, prometheus.GaugeValue, task.LastDuration.Seconds(), task.Name)
+		ch <- prometheus.MustNewConstMetric(c.taskFailing, prometheus.GaugeValue, failing, task.Name)
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	AuthAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_attempts_total",
+		Help: "Authentication attempts by flow, result and failure reason.",
+	}, []string{"flow", "result", "reason"})
+
+	RefreshTokenRotations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_refresh_token_rotations_total",
+		Help: "Refresh tokens successfully rotated.",
+	})
+
+	RefreshTokenReuseDetections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_refresh_token_reuse_detections_total",
+		Help: "Refresh attempts made with an already revoked refresh token.",
+	})
+
+	Lockouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_lockouts_total",
+		Help: "Requests rejected because an account or challenge is locked out.",
+	}, []string{"reason"})
+
+	TokenValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_token_validation_failures_total",
+		Help: "Access token and personal access token validation failures in the auth middleware by reason.",
+	}, []string{"reason"})
+
+	PasswordHashDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "auth_password_hash_duration_seconds",
+		Help:    "Time spent hashing and comparing passwords.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"algorithm", "operation"})
+)
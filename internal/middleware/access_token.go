@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
+	"github.com/labstack/echo/v4"
+)
+
+// AccessTokenMiddleware accepts a personal access token granted scope in an
+// Authorization: Bearer header and otherwise falls back to JWTMiddleware, so
+// handlers see the same userID either way.
+func AccessTokenMiddleware(authService *auth.Service, tokenService *auth.PersonalAccessTokenService, scope string) echo.MiddlewareFunc {
+	jwt := JWTMiddleware(authService)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		cookieAuth := jwt(next)
+
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if header == "" {
+				return cookieAuth(c)
+			}
+
+			scheme, token, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				metrics.TokenValidationFailures.WithLabelValues("invalid").Inc()
+				return echo.NewHTTPError(http.StatusUnauthorized, "Authorization header must be a Bearer token")
+			}
+
+			userID, err := tokenService.Authenticate(c.Request().Context(), strings.TrimSpace(token), scope)
+			if err != nil {
+				switch {
+				case errors.Is(err, auth.ErrExpiredToken):
+					metrics.TokenValidationFailures.WithLabelValues("expired").Inc()
+				case errors.Is(err, auth.ErrInsufficientScope):
+					metrics.TokenValidationFailures.WithLabelValues("insufficient_scope").Inc()
+				case errors.Is(err, auth.ErrInvalidToken):
+					metrics.TokenValidationFailures.WithLabelValues("invalid").Inc()
+				}
+				return err
+			}
+
+			c.Set("userID", userID)
+			logging.WithUserID(c, userID)
+
+			return next(c)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+	"github.com/joacolabadie/go-auth-template-v2/internal/metrics"
 	"github.com/labstack/echo/v4"
 )
 
@@ -13,27 +16,36 @@ func JWTMiddleware(authService *auth.Service) echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			cookie, err := c.Cookie("access_token")
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Missing access_token cookie"})
+				metrics.TokenValidationFailures.WithLabelValues("missing").Inc()
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing access_token cookie")
 			}
 
 			tokenString := cookie.Value
 
 			claims, err := authService.ValidateToken(tokenString)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid or expired token"})
+				if errors.Is(err, auth.ErrExpiredToken) {
+					metrics.TokenValidationFailures.WithLabelValues("expired").Inc()
+				} else {
+					metrics.TokenValidationFailures.WithLabelValues("invalid").Inc()
+				}
+				return err
 			}
 
 			sub, ok := claims["sub"].(string)
 			if !ok || sub == "" {
-				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid token claims"})
+				metrics.TokenValidationFailures.WithLabelValues("invalid_claims").Inc()
+				return auth.ErrInvalidToken
 			}
 
 			userID, err := uuid.Parse(sub)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid user ID in token"})
+				metrics.TokenValidationFailures.WithLabelValues("invalid_subject").Inc()
+				return auth.ErrInvalidToken
 			}
 
 			c.Set("userID", userID)
+			logging.WithUserID(c, userID)
 
 			return next(c)
 		}
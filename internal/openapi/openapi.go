@@ -0,0 +1,15 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+func Handler(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, Spec)
+}
@@ -0,0 +1,33 @@
+package otp
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+const (
+	PurposeLogin         = "login"
+	PurposeChangeEmail   = "change_email"
+	PurposeDeleteAccount = "delete_account"
+	PurposeMFA           = "mfa"
+	PurposeVerifyPhone   = "verify_phone"
+)
+
+type Challenge struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Purpose     string
+	Channel     string
+	Destination string
+	CodeHash    string
+	Attempts    int
+	ExpiresAt   time.Time
+	ConsumedAt  *time.Time
+}
@@ -0,0 +1,147 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type PostgresOTPRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresOTPRepository(db *pgxpool.Pool) *PostgresOTPRepository {
+	return &PostgresOTPRepository{db: db}
+}
+
+func (r *PostgresOTPRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
+func (r *PostgresOTPRepository) CreateChallenge(ctx context.Context, userID uuid.UUID, purpose, channel, destination, code string, ttl time.Duration) (*Challenge, error) {
+	challenge := &Challenge{
+		UserID:      userID,
+		Purpose:     purpose,
+		Channel:     channel,
+		Destination: destination,
+		CodeHash:    utils.HashToken(code),
+		Attempts:    0,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+
+	q := `
+		INSERT INTO otp_challenges (user_id, purpose, channel, destination, code_hash, attempts, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err := r.conn(ctx).QueryRow(ctx, q, challenge.UserID, challenge.Purpose, challenge.Channel, challenge.Destination, challenge.CodeHash, challenge.Attempts, challenge.ExpiresAt).Scan(&challenge.ID, &challenge.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (r *PostgresOTPRepository) GetChallenge(ctx context.Context, id uuid.UUID) (*Challenge, error) {
+	q := `
+		SELECT id, created_at, user_id, purpose, channel, destination, code_hash, attempts, expires_at, consumed_at
+		FROM otp_challenges
+		WHERE id = $1
+	`
+
+	var challenge Challenge
+	var consumedAt pgtype.Timestamptz
+
+	err := r.conn(ctx).QueryRow(ctx, q, id).Scan(
+		&challenge.ID,
+		&challenge.CreatedAt,
+		&challenge.UserID,
+		&challenge.Purpose,
+		&challenge.Channel,
+		&challenge.Destination,
+		&challenge.CodeHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&consumedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if consumedAt.Valid {
+		challenge.ConsumedAt = &consumedAt.Time
+	}
+
+	return &challenge, nil
+}
+
+func (r *PostgresOTPRepository) IncrementAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error) {
+	q := `
+		UPDATE otp_challenges
+		SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2
+		RETURNING attempts
+	`
+
+	var attempts int
+
+	err := r.conn(ctx).QueryRow(ctx, q, id, maxAttempts).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, storage.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (r *PostgresOTPRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE otp_challenges
+		SET consumed_at = $1
+		WHERE id = $2 AND consumed_at IS NULL
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresOTPRepository) DeleteExpiredChallenges(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM otp_challenges
+		WHERE id IN (
+			SELECT id
+			FROM otp_challenges
+			WHERE consumed_at IS NOT NULL OR expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
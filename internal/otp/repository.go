@@ -0,0 +1,16 @@
+package otp
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	CreateChallenge(ctx context.Context, userID uuid.UUID, purpose, channel, destination, code string, ttl time.Duration) (*Challenge, error)
+	GetChallenge(ctx context.Context, id uuid.UUID) (*Challenge, error)
+	IncrementAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error)
+	ConsumeChallenge(ctx context.Context, id uuid.UUID) error
+	DeleteExpiredChallenges(ctx context.Context, batchSize int) (int64, error)
+}
@@ -0,0 +1,153 @@
+package otp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type SQLiteOTPRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteOTPRepository(db *sql.DB) *SQLiteOTPRepository {
+	return &SQLiteOTPRepository{db: db}
+}
+
+func (r *SQLiteOTPRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLiteOTPRepository) CreateChallenge(ctx context.Context, userID uuid.UUID, purpose, channel, destination, code string, ttl time.Duration) (*Challenge, error) {
+	now := time.Now().UTC()
+
+	challenge := &Challenge{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UserID:      userID,
+		Purpose:     purpose,
+		Channel:     channel,
+		Destination: destination,
+		CodeHash:    utils.HashToken(code),
+		Attempts:    0,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	q := `
+		INSERT INTO otp_challenges (id, created_at, user_id, purpose, channel, destination, code_hash, attempts, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, challenge.ID, challenge.CreatedAt, challenge.UserID, challenge.Purpose, challenge.Channel, challenge.Destination, challenge.CodeHash, challenge.Attempts, challenge.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (r *SQLiteOTPRepository) GetChallenge(ctx context.Context, id uuid.UUID) (*Challenge, error) {
+	q := `
+		SELECT id, created_at, user_id, purpose, channel, destination, code_hash, attempts, expires_at, consumed_at
+		FROM otp_challenges
+		WHERE id = ?
+	`
+
+	var challenge Challenge
+	var consumedAt sql.NullTime
+
+	err := r.conn(ctx).QueryRowContext(ctx, q, id).Scan(
+		&challenge.ID,
+		&challenge.CreatedAt,
+		&challenge.UserID,
+		&challenge.Purpose,
+		&challenge.Channel,
+		&challenge.Destination,
+		&challenge.CodeHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&consumedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if consumedAt.Valid {
+		challenge.ConsumedAt = &consumedAt.Time
+	}
+
+	return &challenge, nil
+}
+
+func (r *SQLiteOTPRepository) IncrementAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error) {
+	q := `
+		UPDATE otp_challenges
+		SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ?
+		RETURNING attempts
+	`
+
+	var attempts int
+
+	err := r.conn(ctx).QueryRowContext(ctx, q, id, maxAttempts).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (r *SQLiteOTPRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE otp_challenges
+		SET consumed_at = ?
+		WHERE id = ? AND consumed_at IS NULL
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteOTPRepository) DeleteExpiredChallenges(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM otp_challenges
+		WHERE id IN (
+			SELECT id
+			FROM otp_challenges
+			WHERE consumed_at IS NOT NULL OR expires_at < ?
+			LIMIT ?
+		)
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,67 @@
+package personalaccesstoken
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+// TokenPrefix starts every personal access token so they are easy to tell
+// apart from other credentials, e.g. by secret scanners
+const TokenPrefix = "gat_"
+
+// displayPrefixLength is how much of the token, after TokenPrefix, is kept in
+// the clear so users can tell their tokens apart
+const displayPrefixLength = 8
+
+const (
+	ScopeProfileRead   = "profile:read"
+	ScopeProfileWrite  = "profile:write"
+	ScopeAccountExport = "account:export"
+)
+
+// Scopes lists every scope a token can be granted
+var Scopes = []string{ScopeProfileRead, ScopeProfileWrite, ScopeAccountExport}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	Token      string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// HasScope reports whether the token was granted scope
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPersonalAccessToken reports whether token looks like one of ours, which is
+// cheaper than looking it up
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+func generateToken() (raw, prefix string, err error) {
+	random, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	raw = TokenPrefix + random
+
+	return raw, raw[:len(TokenPrefix)+displayPrefixLength], nil
+}
@@ -0,0 +1,200 @@
+// Package personalaccesstokentest holds the conformance suite every
+// personalaccesstoken.Repository implementation must pass.
+package personalaccesstokentest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+)
+
+// RunRepositoryTests exercises repo through the personalaccesstoken.Repository
+// interface. newUserID returns the ID of a user the tokens can belong to,
+// which for database backends must exist to satisfy the foreign key.
+func RunRepositoryTests(t *testing.T, newRepo func(t *testing.T) personalaccesstoken.Repository, newUserID func(t *testing.T) uuid.UUID) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+		expiresAt := time.Now().Add(time.Hour)
+		scopes := []string{personalaccesstoken.ScopeProfileRead, personalaccesstoken.ScopeAccountExport}
+
+		created, err := repo.CreatePersonalAccessToken(ctx, userID, "ci", scopes, &expiresAt)
+		if err != nil {
+			t.Fatalf("CreatePersonalAccessToken: %v", err)
+		}
+		if created.ID == uuid.Nil || created.CreatedAt.IsZero() {
+			t.Error("CreatePersonalAccessToken did not set ID and CreatedAt")
+		}
+		if !personalaccesstoken.IsPersonalAccessToken(created.Token) || !strings.HasPrefix(created.Token, created.Prefix) {
+			t.Errorf("CreatePersonalAccessToken token %q, prefix %q: want a prefixed token starting with its prefix", created.Token, created.Prefix)
+		}
+
+		got, err := repo.GetPersonalAccessToken(ctx, created.Token)
+		if err != nil {
+			t.Fatalf("GetPersonalAccessToken: %v", err)
+		}
+		if got.ID != created.ID || got.UserID != userID || got.Name != "ci" || got.Prefix != created.Prefix {
+			t.Errorf("GetPersonalAccessToken = %+v, want %+v", got, created)
+		}
+		if !got.HasScope(personalaccesstoken.ScopeProfileRead) || !got.HasScope(personalaccesstoken.ScopeAccountExport) || got.HasScope(personalaccesstoken.ScopeProfileWrite) {
+			t.Errorf("Scopes = %v, want %v", got.Scopes, scopes)
+		}
+		if got.ExpiresAt == nil || got.ExpiresAt.Sub(expiresAt).Abs() > time.Millisecond {
+			t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, expiresAt)
+		}
+		if got.LastUsedAt != nil || got.RevokedAt != nil {
+			t.Errorf("new token has LastUsedAt %v, RevokedAt %v, want neither", got.LastUsedAt, got.RevokedAt)
+		}
+
+		if _, err := repo.GetPersonalAccessToken(ctx, personalaccesstoken.TokenPrefix+uuid.NewString()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetPersonalAccessToken with an unknown token error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("NoExpiry", func(t *testing.T) {
+		repo := newRepo(t)
+
+		created := mustCreate(t, repo, newUserID(t), nil)
+
+		if got := mustGet(t, repo, created.Token); got.ExpiresAt != nil {
+			t.Errorf("ExpiresAt = %v, want nil", got.ExpiresAt)
+		}
+	})
+
+	t.Run("RevokeAndList", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+		otherUserID := newUserID(t)
+
+		older := mustCreate(t, repo, userID, nil)
+		revoked := mustCreate(t, repo, userID, nil)
+		newer := mustCreate(t, repo, userID, nil)
+		otherUsers := mustCreate(t, repo, otherUserID, nil)
+
+		if err := repo.RevokePersonalAccessToken(ctx, userID, revoked.ID); err != nil {
+			t.Fatalf("RevokePersonalAccessToken: %v", err)
+		}
+		if err := repo.RevokePersonalAccessToken(ctx, userID, revoked.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RevokePersonalAccessToken twice error = %v, want storage.ErrNotFound", err)
+		}
+		if err := repo.RevokePersonalAccessToken(ctx, userID, otherUsers.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RevokePersonalAccessToken of another user's token error = %v, want storage.ErrNotFound", err)
+		}
+
+		if mustGet(t, repo, revoked.Token).RevokedAt == nil {
+			t.Error("revoked token has no RevokedAt")
+		}
+		if mustGet(t, repo, otherUsers.Token).RevokedAt != nil {
+			t.Error("another user's token was revoked")
+		}
+
+		tokens, err := repo.ListUserPersonalAccessTokens(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListUserPersonalAccessTokens: %v", err)
+		}
+		if len(tokens) != 2 {
+			t.Fatalf("ListUserPersonalAccessTokens returned %d tokens, want 2", len(tokens))
+		}
+		if tokens[0].ID != newer.ID || tokens[1].ID != older.ID {
+			t.Error("ListUserPersonalAccessTokens is not ordered newest first")
+		}
+		for _, token := range tokens {
+			if token.Token != "" {
+				t.Error("ListUserPersonalAccessTokens exposed a token value")
+			}
+		}
+	})
+
+	t.Run("TouchPersonalAccessToken", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		created := mustCreate(t, repo, newUserID(t), nil)
+
+		if err := repo.TouchPersonalAccessToken(ctx, created.ID); err != nil {
+			t.Fatalf("TouchPersonalAccessToken: %v", err)
+		}
+
+		first := mustGet(t, repo, created.Token).LastUsedAt
+		if first == nil {
+			t.Fatal("LastUsedAt was not set")
+		}
+
+		// A second use right away is within the resolution and not written
+		if err := repo.TouchPersonalAccessToken(ctx, created.ID); err != nil {
+			t.Fatalf("TouchPersonalAccessToken: %v", err)
+		}
+		if second := mustGet(t, repo, created.Token).LastUsedAt; second == nil || !second.Equal(*first) {
+			t.Errorf("LastUsedAt = %v after a second use, want %v", second, first)
+		}
+	})
+
+	t.Run("DeleteExpiredPersonalAccessTokens", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+
+		expired := mustCreate(t, repo, userID, &past)
+		revoked := mustCreate(t, repo, userID, &future)
+		live := mustCreate(t, repo, userID, &future)
+		unlimited := mustCreate(t, repo, userID, nil)
+
+		if err := repo.RevokePersonalAccessToken(ctx, userID, revoked.ID); err != nil {
+			t.Fatalf("RevokePersonalAccessToken: %v", err)
+		}
+
+		// Drain in batches since the store may hold other tests' expired tokens
+		for {
+			deleted, err := repo.DeleteExpiredPersonalAccessTokens(ctx, 100)
+			if err != nil {
+				t.Fatalf("DeleteExpiredPersonalAccessTokens: %v", err)
+			}
+			if deleted > 100 {
+				t.Fatalf("DeleteExpiredPersonalAccessTokens deleted %d tokens, want at most the batch size", deleted)
+			}
+			if deleted < 100 {
+				break
+			}
+		}
+
+		for _, token := range []*personalaccesstoken.PersonalAccessToken{expired, revoked} {
+			if _, err := repo.GetPersonalAccessToken(ctx, token.Token); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("GetPersonalAccessToken after cleanup error = %v, want storage.ErrNotFound", err)
+			}
+		}
+		mustGet(t, repo, live.Token)
+		mustGet(t, repo, unlimited.Token)
+	})
+}
+
+func mustCreate(t *testing.T, repo personalaccesstoken.Repository, userID uuid.UUID, expiresAt *time.Time) *personalaccesstoken.PersonalAccessToken {
+	t.Helper()
+
+	token, err := repo.CreatePersonalAccessToken(context.Background(), userID, "test", []string{personalaccesstoken.ScopeProfileRead}, expiresAt)
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+
+	return token
+}
+
+func mustGet(t *testing.T, repo personalaccesstoken.Repository, rawToken string) *personalaccesstoken.PersonalAccessToken {
+	t.Helper()
+
+	token, err := repo.GetPersonalAccessToken(context.Background(), rawToken)
+	if err != nil {
+		t.Fatalf("GetPersonalAccessToken: %v", err)
+	}
+
+	return token
+}
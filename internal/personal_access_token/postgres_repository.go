@@ -0,0 +1,193 @@
+package personalaccesstoken
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type PostgresPersonalAccessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPersonalAccessTokenRepository(db *pgxpool.Pool) *PostgresPersonalAccessTokenRepository {
+	return &PostgresPersonalAccessTokenRepository{db: db}
+}
+
+func (r *PostgresPersonalAccessTokenRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
+func (r *PostgresPersonalAccessTokenRepository) CreatePersonalAccessToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, error) {
+	rawToken, prefix, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Token:     rawToken,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+
+	q := `
+		INSERT INTO personal_access_tokens (user_id, name, token_prefix, token, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err = r.conn(ctx).QueryRow(ctx, q, token.UserID, token.Name, token.Prefix, utils.HashToken(rawToken), token.Scopes, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *PostgresPersonalAccessTokenRepository) GetPersonalAccessToken(ctx context.Context, rawToken string) (*PersonalAccessToken, error) {
+	q := `
+		SELECT id, created_at, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at
+		FROM personal_access_tokens
+		WHERE token = $1
+	`
+
+	token, err := scanPersonalAccessToken(r.conn(ctx).QueryRow(ctx, q, utils.HashToken(rawToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = rawToken
+
+	return token, nil
+}
+
+func (r *PostgresPersonalAccessTokenRepository) ListUserPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error) {
+	q := `
+		SELECT id, created_at, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []PersonalAccessToken
+
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *PostgresPersonalAccessTokenRepository) RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	q := `
+		UPDATE personal_access_tokens
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresPersonalAccessTokenRepository) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+
+	q := `
+		UPDATE personal_access_tokens
+		SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, now, id, now.Add(-lastUsedResolution))
+
+	return err
+}
+
+// Revoked tokens are kept until they are swept up here, like expired ones
+func (r *PostgresPersonalAccessTokenRepository) DeleteExpiredPersonalAccessTokens(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM personal_access_tokens
+		WHERE id IN (
+			SELECT id
+			FROM personal_access_tokens
+			WHERE revoked_at IS NOT NULL OR expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanPersonalAccessToken(row pgx.Row) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	var expiresAt pgtype.Timestamptz
+	var lastUsedAt pgtype.Timestamptz
+	var revokedAt pgtype.Timestamptz
+
+	err := row.Scan(
+		&token.ID,
+		&token.CreatedAt,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&token.Scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,24 @@
+package personalaccesstoken_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token/personalaccesstokentest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestPostgresPersonalAccessTokenRepository(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	users := user.NewPostgresUserRepository(pool)
+
+	personalaccesstokentest.RunRepositoryTests(
+		t,
+		func(t *testing.T) personalaccesstoken.Repository {
+			return personalaccesstoken.NewPostgresPersonalAccessTokenRepository(pool)
+		},
+		usertest.NewUserID(users),
+	)
+}
@@ -0,0 +1,27 @@
+package personalaccesstoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// lastUsedResolution limits how often TouchPersonalAccessToken writes, so a
+// busy token doesn't cost an UPDATE per request
+const lastUsedResolution = time.Minute
+
+type Repository interface {
+	CreatePersonalAccessToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, error)
+	GetPersonalAccessToken(ctx context.Context, tokenString string) (*PersonalAccessToken, error)
+	// ListUserPersonalAccessTokens returns the user's tokens that haven't been
+	// revoked, newest first, without their secret
+	ListUserPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error)
+	// RevokePersonalAccessToken returns storage.ErrNotFound unless the user has
+	// an unrevoked token with the ID
+	RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error
+	// TouchPersonalAccessToken records that the token was just used. Uses
+	// within lastUsedResolution of the recorded one are not written.
+	TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error
+	DeleteExpiredPersonalAccessTokens(ctx context.Context, batchSize int) (int64, error)
+}
@@ -0,0 +1,223 @@
+package personalaccesstoken
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type SQLitePersonalAccessTokenRepository struct {
+	db *sql.DB
+}
+
+func NewSQLitePersonalAccessTokenRepository(db *sql.DB) *SQLitePersonalAccessTokenRepository {
+	return &SQLitePersonalAccessTokenRepository{db: db}
+}
+
+func (r *SQLitePersonalAccessTokenRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLitePersonalAccessTokenRepository) CreatePersonalAccessToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, error) {
+	rawToken, prefix, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite has no arrays, so scopes are stored as a JSON array
+	encodedScopes, err := json.Marshal(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &PersonalAccessToken{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Token:     rawToken,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+
+	var expiresAtValue sql.NullTime
+	if expiresAt != nil {
+		expiresAtValue = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+
+	q := `
+		INSERT INTO personal_access_tokens (id, created_at, user_id, name, token_prefix, token, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = r.conn(ctx).ExecContext(ctx, q,
+		token.ID,
+		token.CreatedAt,
+		token.UserID,
+		token.Name,
+		token.Prefix,
+		utils.HashToken(rawToken),
+		string(encodedScopes),
+		expiresAtValue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *SQLitePersonalAccessTokenRepository) GetPersonalAccessToken(ctx context.Context, rawToken string) (*PersonalAccessToken, error) {
+	q := `
+		SELECT id, created_at, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at
+		FROM personal_access_tokens
+		WHERE token = ?
+	`
+
+	token, err := scanSQLitePersonalAccessToken(r.conn(ctx).QueryRowContext(ctx, q, utils.HashToken(rawToken)))
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = rawToken
+
+	return token, nil
+}
+
+func (r *SQLitePersonalAccessTokenRepository) ListUserPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error) {
+	q := `
+		SELECT id, created_at, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at
+		FROM personal_access_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []PersonalAccessToken
+
+	for rows.Next() {
+		token, err := scanSQLitePersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *SQLitePersonalAccessTokenRepository) RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	q := `
+		UPDATE personal_access_tokens
+		SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLitePersonalAccessTokenRepository) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	now := time.Now().UTC()
+
+	q := `
+		UPDATE personal_access_tokens
+		SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, now, id, now.Add(-lastUsedResolution))
+
+	return err
+}
+
+// Revoked tokens are kept until they are swept up here, like expired ones
+func (r *SQLitePersonalAccessTokenRepository) DeleteExpiredPersonalAccessTokens(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM personal_access_tokens
+		WHERE id IN (
+			SELECT id
+			FROM personal_access_tokens
+			WHERE revoked_at IS NOT NULL OR expires_at < ?
+			LIMIT ?
+		)
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanSQLitePersonalAccessToken(row interface{ Scan(dest ...any) error }) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	var scopes string
+	var expiresAt sql.NullTime
+	var lastUsedAt sql.NullTime
+	var revokedAt sql.NullTime
+
+	err := row.Scan(
+		&token.ID,
+		&token.CreatedAt,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &token.Scopes); err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
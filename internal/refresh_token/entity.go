@@ -10,6 +10,9 @@ type RefreshToken struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UserID    uuid.UUID
+	// FamilyID is shared by every token rotated from the same login, so it
+	// identifies the session while the token itself changes
+	FamilyID  uuid.UUID
 	Token     string
 	ExpiresAt time.Time
 	Revoked   bool
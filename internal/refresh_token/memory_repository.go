@@ -0,0 +1,177 @@
+package refreshtoken
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+// MemoryRefreshTokenRepository keeps refresh tokens in a map keyed by their
+// hash, following the Postgres repository's semantics. It is meant for tests
+// and local experiments.
+type MemoryRefreshTokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[string]*RefreshToken
+}
+
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{tokens: make(map[string]*RefreshToken)}
+}
+
+func (r *MemoryRefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(userID, uuid.New(), ttl)
+}
+
+func (r *MemoryRefreshTokenRepository) ReplaceRefreshToken(ctx context.Context, consumed *RefreshToken, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(consumed.UserID, consumed.FamilyID, ttl)
+}
+
+func (r *MemoryRefreshTokenRepository) create(userID, familyID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
+	rawToken := uuid.New().String()
+	hashedToken := utils.HashToken(rawToken)
+
+	now := time.Now()
+
+	token := &RefreshToken{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    userID,
+		FamilyID:  familyID,
+		Token:     hashedToken,
+		ExpiresAt: now.Add(ttl),
+		Revoked:   false,
+	}
+
+	r.mu.Lock()
+	r.tokens[hashedToken] = token
+	r.mu.Unlock()
+
+	created := *token
+	created.Token = rawToken
+
+	return &created, nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetRefreshToken(ctx context.Context, rawToken string) (*RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.tokens[utils.HashToken(rawToken)]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	token := *stored
+	token.Token = rawToken
+
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tokens []RefreshToken
+
+	for _, stored := range r.tokens {
+		if stored.UserID != userID {
+			continue
+		}
+
+		// The hash is never handed out, matching the Postgres listing
+		token := *stored
+		token.Token = ""
+
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+
+	return tokens, nil
+}
+
+func (r *MemoryRefreshTokenRepository) ConsumeRefreshToken(ctx context.Context, rawToken string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tokens[utils.HashToken(rawToken)]
+	if !ok || stored.Revoked {
+		return nil, storage.ErrNotFound
+	}
+
+	stored.Revoked = true
+
+	token := *stored
+	token.Token = rawToken
+
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeRefreshToken(ctx context.Context, rawToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token, ok := r.tokens[utils.HashToken(rawToken)]; ok {
+		token.Revoked = true
+	}
+
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeUserRefreshToken(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.ID == id && token.UserID == userID && !token.Revoked {
+			token.Revoked = true
+			return nil
+		}
+	}
+
+	return storage.ErrNotFound
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID, exceptFamilyID *uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.UserID != userID || (exceptFamilyID != nil && token.FamilyID == *exceptFamilyID) {
+			continue
+		}
+
+		token.Revoked = true
+	}
+
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, batchSize int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	var deleted int64
+
+	for hashedToken, token := range r.tokens {
+		if deleted >= int64(batchSize) {
+			break
+		}
+
+		if token.ExpiresAt.Before(now) {
+			delete(r.tokens, hashedToken)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,21 @@
+package refreshtoken_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/refresh_token/refreshtokentest"
+)
+
+func TestMemoryRefreshTokenRepository(t *testing.T) {
+	refreshtokentest.RunRepositoryTests(
+		t,
+		func(t *testing.T) refreshtoken.Repository {
+			return refreshtoken.NewMemoryRefreshTokenRepository()
+		},
+		func(t *testing.T) uuid.UUID {
+			return uuid.New()
+		},
+	)
+}
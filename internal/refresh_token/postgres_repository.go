@@ -2,10 +2,14 @@ package refreshtoken
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
 	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
 )
 
@@ -17,23 +21,37 @@ func NewPostgresRefreshTokenRepository(db *pgxpool.Pool) *PostgresRefreshTokenRe
 	return &PostgresRefreshTokenRepository{db: db}
 }
 
+func (r *PostgresRefreshTokenRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
 func (r *PostgresRefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(ctx, userID, uuid.New(), ttl)
+}
+
+func (r *PostgresRefreshTokenRepository) ReplaceRefreshToken(ctx context.Context, consumed *RefreshToken, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(ctx, consumed.UserID, consumed.FamilyID, ttl)
+}
+
+func (r *PostgresRefreshTokenRepository) create(ctx context.Context, userID, familyID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
 	rawToken := uuid.New().String()
 	hashedToken := utils.HashToken(rawToken)
 
 	token := &RefreshToken{
 		UserID:    userID,
+		FamilyID:  familyID,
 		Token:     rawToken,
 		ExpiresAt: time.Now().Add(ttl),
 		Revoked:   false,
 	}
 
 	q := `
-		INSERT INTO refresh_tokens (user_id, token, expires_at, revoked)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO refresh_tokens (user_id, family_id, token, expires_at, revoked)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
 	`
 
-	_, err := r.db.Exec(ctx, q, token.UserID, hashedToken, token.ExpiresAt, token.Revoked)
+	err := r.conn(ctx).QueryRow(ctx, q, token.UserID, token.FamilyID, hashedToken, token.ExpiresAt, token.Revoked).Scan(&token.ID, &token.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -47,19 +65,92 @@ func (r *PostgresRefreshTokenRepository) GetRefreshToken(ctx context.Context, ra
 	hashedToken := utils.HashToken(rawToken)
 
 	q := `
-		SELECT id, created_at, user_id, token, expires_at, revoked
+		SELECT id, created_at, user_id, family_id, token, expires_at, revoked
 		FROM refresh_tokens
 		WHERE token = $1
 	`
 
-	err := r.db.QueryRow(ctx, q, hashedToken).Scan(
+	err := r.conn(ctx).QueryRow(ctx, q, hashedToken).Scan(
 		&token.ID,
 		&token.CreatedAt,
 		&token.UserID,
+		&token.FamilyID,
 		&token.Token,
 		&token.ExpiresAt,
 		&token.Revoked,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = rawToken
+
+	return &token, nil
+}
+
+func (r *PostgresRefreshTokenRepository) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	q := `
+		SELECT id, created_at, user_id, family_id, expires_at, revoked
+		FROM refresh_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []RefreshToken
+
+	for rows.Next() {
+		var token RefreshToken
+
+		err := rows.Scan(
+			&token.ID,
+			&token.CreatedAt,
+			&token.UserID,
+			&token.FamilyID,
+			&token.ExpiresAt,
+			&token.Revoked,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *PostgresRefreshTokenRepository) ConsumeRefreshToken(ctx context.Context, rawToken string) (*RefreshToken, error) {
+	var token RefreshToken
+
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE token = $1 AND revoked = false
+		RETURNING id, created_at, user_id, family_id, expires_at, revoked
+	`
+
+	err := r.conn(ctx).QueryRow(ctx, q, hashedToken).Scan(
+		&token.ID,
+		&token.CreatedAt,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.Revoked,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +169,57 @@ func (r *PostgresRefreshTokenRepository) RevokeRefreshToken(ctx context.Context,
 		WHERE token = $1
 	`
 
-	_, err := r.db.Exec(ctx, q, hashedToken)
+	_, err := r.conn(ctx).Exec(ctx, q, hashedToken)
+
+	return err
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeUserRefreshToken(ctx context.Context, userID, id uuid.UUID) error {
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE id = $1 AND user_id = $2 AND revoked = false
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID, exceptFamilyID *uuid.UUID) error {
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE user_id = $1 AND revoked = false AND ($2::uuid IS NULL OR family_id <> $2)
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, userID, exceptFamilyID)
 
 	return err
 }
+
+func (r *PostgresRefreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM refresh_tokens
+		WHERE id IN (
+			SELECT id
+			FROM refresh_tokens
+			WHERE expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
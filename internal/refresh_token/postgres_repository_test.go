@@ -0,0 +1,24 @@
+package refreshtoken_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/refresh_token/refreshtokentest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestPostgresRefreshTokenRepository(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	users := user.NewPostgresUserRepository(pool)
+
+	refreshtokentest.RunRepositoryTests(
+		t,
+		func(t *testing.T) refreshtoken.Repository {
+			return refreshtoken.NewPostgresRefreshTokenRepository(pool)
+		},
+		usertest.NewUserID(users),
+	)
+}
@@ -0,0 +1,338 @@
+// Package refreshtokentest holds the conformance suite every
+// refreshtoken.Repository implementation must pass.
+package refreshtokentest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+)
+
+// RunRepositoryTests exercises repo through the refreshtoken.Repository
+// interface. newUserID returns the ID of a user the tokens can belong to,
+// which for database backends must exist to satisfy the foreign key.
+func RunRepositoryTests(t *testing.T, newRepo func(t *testing.T) refreshtoken.Repository, newUserID func(t *testing.T) uuid.UUID) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		created, err := repo.CreateRefreshToken(ctx, userID, time.Hour)
+		if err != nil {
+			t.Fatalf("CreateRefreshToken: %v", err)
+		}
+		if created.ID == uuid.Nil || created.FamilyID == uuid.Nil || created.CreatedAt.IsZero() {
+			t.Error("CreateRefreshToken did not set ID, FamilyID and CreatedAt")
+		}
+		if created.Token == "" || created.Revoked {
+			t.Errorf("CreateRefreshToken = %+v, want an unrevoked token", created)
+		}
+
+		got, err := repo.GetRefreshToken(ctx, created.Token)
+		if err != nil {
+			t.Fatalf("GetRefreshToken: %v", err)
+		}
+		if got.ID != created.ID || got.UserID != userID || got.FamilyID != created.FamilyID || got.Token != created.Token {
+			t.Errorf("GetRefreshToken = %+v, want %+v", got, created)
+		}
+		if d := got.ExpiresAt.Sub(created.ExpiresAt); d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, created.ExpiresAt)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetRefreshToken(context.Background(), uuid.NewString()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetRefreshToken error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("RevokeRefreshToken", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		revoked := mustCreate(t, repo, userID, time.Hour)
+		kept := mustCreate(t, repo, userID, time.Hour)
+
+		if err := repo.RevokeRefreshToken(ctx, revoked.Token); err != nil {
+			t.Fatalf("RevokeRefreshToken: %v", err)
+		}
+		// Unknown tokens are ignored
+		if err := repo.RevokeRefreshToken(ctx, uuid.NewString()); err != nil {
+			t.Fatalf("RevokeRefreshToken with an unknown token: %v", err)
+		}
+
+		if !mustGet(t, repo, revoked.Token).Revoked {
+			t.Error("revoked token is not marked revoked")
+		}
+		if mustGet(t, repo, kept.Token).Revoked {
+			t.Error("unrelated token was revoked")
+		}
+	})
+
+	t.Run("ConsumeRefreshToken", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		created := mustCreate(t, repo, userID, time.Hour)
+
+		consumed, err := repo.ConsumeRefreshToken(ctx, created.Token)
+		if err != nil {
+			t.Fatalf("ConsumeRefreshToken: %v", err)
+		}
+		if consumed.ID != created.ID || consumed.UserID != userID || !consumed.Revoked {
+			t.Errorf("ConsumeRefreshToken = %+v, want the revoked token %v", consumed, created.ID)
+		}
+		if !mustGet(t, repo, created.Token).Revoked {
+			t.Error("consumed token is not marked revoked")
+		}
+
+		if _, err := repo.ConsumeRefreshToken(ctx, created.Token); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("ConsumeRefreshToken twice error = %v, want storage.ErrNotFound", err)
+		}
+		if _, err := repo.ConsumeRefreshToken(ctx, uuid.NewString()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("ConsumeRefreshToken with an unknown token error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ConcurrentConsume", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		created := mustCreate(t, repo, newUserID(t), time.Hour)
+
+		const attempts = 10
+
+		var wg sync.WaitGroup
+		errs := make(chan error, attempts)
+
+		for range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := repo.ConsumeRefreshToken(ctx, created.Token)
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		consumed := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				consumed++
+			case !errors.Is(err, storage.ErrNotFound):
+				t.Errorf("ConsumeRefreshToken error = %v, want storage.ErrNotFound", err)
+			}
+		}
+		if consumed != 1 {
+			t.Errorf("%d concurrent ConsumeRefreshToken calls succeeded, want 1", consumed)
+		}
+	})
+
+	t.Run("ReplaceRefreshToken", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		first := mustCreate(t, repo, userID, time.Hour)
+		other := mustCreate(t, repo, userID, time.Hour)
+		if other.FamilyID == first.FamilyID {
+			t.Error("CreateRefreshToken reused a family")
+		}
+
+		consumed, err := repo.ConsumeRefreshToken(ctx, first.Token)
+		if err != nil {
+			t.Fatalf("ConsumeRefreshToken: %v", err)
+		}
+
+		replacement, err := repo.ReplaceRefreshToken(ctx, consumed, time.Hour)
+		if err != nil {
+			t.Fatalf("ReplaceRefreshToken: %v", err)
+		}
+		if replacement.ID == first.ID || replacement.Token == first.Token || replacement.Revoked {
+			t.Errorf("ReplaceRefreshToken = %+v, want a new unrevoked token", replacement)
+		}
+
+		got := mustGet(t, repo, replacement.Token)
+		if got.FamilyID != first.FamilyID || got.UserID != userID {
+			t.Errorf("replacement family = %s for user %s, want %s for %s", got.FamilyID, got.UserID, first.FamilyID, userID)
+		}
+	})
+
+	t.Run("RevokeUserRefreshTokens", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+		otherUserID := newUserID(t)
+
+		first := mustCreate(t, repo, userID, time.Hour)
+		other := mustCreate(t, repo, userID, time.Hour)
+		otherUsers := mustCreate(t, repo, otherUserID, time.Hour)
+
+		// The session was refreshed since it was identified
+		consumed, err := repo.ConsumeRefreshToken(ctx, first.Token)
+		if err != nil {
+			t.Fatalf("ConsumeRefreshToken: %v", err)
+		}
+		current, err := repo.ReplaceRefreshToken(ctx, consumed, time.Hour)
+		if err != nil {
+			t.Fatalf("ReplaceRefreshToken: %v", err)
+		}
+
+		if err := repo.RevokeUserRefreshTokens(ctx, userID, &first.FamilyID); err != nil {
+			t.Fatalf("RevokeUserRefreshTokens: %v", err)
+		}
+
+		if mustGet(t, repo, current.Token).Revoked {
+			t.Error("token of the excepted family was revoked")
+		}
+		if !mustGet(t, repo, other.Token).Revoked {
+			t.Error("other session of the user was not revoked")
+		}
+		if mustGet(t, repo, otherUsers.Token).Revoked {
+			t.Error("another user's token was revoked")
+		}
+
+		if err := repo.RevokeUserRefreshTokens(ctx, userID, nil); err != nil {
+			t.Fatalf("RevokeUserRefreshTokens: %v", err)
+		}
+		if !mustGet(t, repo, current.Token).Revoked {
+			t.Error("token was not revoked without an exception")
+		}
+	})
+
+	t.Run("RevokeUserRefreshToken", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		token := mustCreate(t, repo, userID, time.Hour)
+		other := mustCreate(t, repo, userID, time.Hour)
+
+		if err := repo.RevokeUserRefreshToken(ctx, newUserID(t), token.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RevokeUserRefreshToken for another user error = %v, want storage.ErrNotFound", err)
+		}
+		if mustGet(t, repo, token.Token).Revoked {
+			t.Fatal("another user revoked the token")
+		}
+
+		if err := repo.RevokeUserRefreshToken(ctx, userID, token.ID); err != nil {
+			t.Fatalf("RevokeUserRefreshToken: %v", err)
+		}
+		if !mustGet(t, repo, token.Token).Revoked {
+			t.Error("token was not revoked")
+		}
+		if mustGet(t, repo, other.Token).Revoked {
+			t.Error("another token of the user was revoked")
+		}
+
+		if err := repo.RevokeUserRefreshToken(ctx, userID, token.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RevokeUserRefreshToken of a revoked token error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListUserRefreshTokens", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		older := mustCreate(t, repo, userID, time.Hour)
+		newer := mustCreate(t, repo, userID, time.Hour)
+		mustCreate(t, repo, newUserID(t), time.Hour)
+
+		tokens, err := repo.ListUserRefreshTokens(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListUserRefreshTokens: %v", err)
+		}
+		if len(tokens) != 2 {
+			t.Fatalf("ListUserRefreshTokens returned %d tokens, want 2", len(tokens))
+		}
+		if tokens[0].ID != newer.ID || tokens[1].ID != older.ID {
+			t.Error("ListUserRefreshTokens is not ordered newest first")
+		}
+		for _, token := range tokens {
+			if token.Token != "" {
+				t.Error("ListUserRefreshTokens exposed a token value")
+			}
+		}
+
+		none, err := repo.ListUserRefreshTokens(ctx, uuid.New())
+		if err != nil {
+			t.Fatalf("ListUserRefreshTokens: %v", err)
+		}
+		if len(none) != 0 {
+			t.Errorf("ListUserRefreshTokens for an unknown user returned %d tokens", len(none))
+		}
+	})
+
+	t.Run("DeleteExpiredRefreshTokens", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		userID := newUserID(t)
+
+		expired := mustCreate(t, repo, userID, -time.Hour)
+		revoked := mustCreate(t, repo, userID, time.Hour)
+		live := mustCreate(t, repo, userID, time.Hour)
+
+		if err := repo.RevokeRefreshToken(ctx, revoked.Token); err != nil {
+			t.Fatalf("RevokeRefreshToken: %v", err)
+		}
+
+		// Drain in batches since the store may hold other tests' expired tokens
+		for {
+			deleted, err := repo.DeleteExpiredRefreshTokens(ctx, 100)
+			if err != nil {
+				t.Fatalf("DeleteExpiredRefreshTokens: %v", err)
+			}
+			if deleted > 100 {
+				t.Fatalf("DeleteExpiredRefreshTokens deleted %d tokens, want at most the batch size", deleted)
+			}
+			if deleted < 100 {
+				break
+			}
+		}
+
+		if _, err := repo.GetRefreshToken(ctx, expired.Token); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetRefreshToken after cleanup error = %v, want storage.ErrNotFound", err)
+		}
+		// Revoked tokens that haven't expired are kept for reuse detection
+		if !mustGet(t, repo, revoked.Token).Revoked {
+			t.Error("revoked token lost its revocation")
+		}
+		mustGet(t, repo, live.Token)
+	})
+}
+
+func mustCreate(t *testing.T, repo refreshtoken.Repository, userID uuid.UUID, ttl time.Duration) *refreshtoken.RefreshToken {
+	t.Helper()
+
+	token, err := repo.CreateRefreshToken(context.Background(), userID, ttl)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	return token
+}
+
+func mustGet(t *testing.T, repo refreshtoken.Repository, rawToken string) *refreshtoken.RefreshToken {
+	t.Helper()
+
+	token, err := repo.GetRefreshToken(context.Background(), rawToken)
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+
+	return token
+}
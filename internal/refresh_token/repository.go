@@ -8,7 +8,25 @@ import (
 )
 
 type Repository interface {
+	// CreateRefreshToken starts a new family, i.e. a new session
 	CreateRefreshToken(ctx context.Context, userID uuid.UUID, ttl time.Duration) (*RefreshToken, error)
+	// ReplaceRefreshToken creates the token that takes over from consumed, in
+	// the same family
+	ReplaceRefreshToken(ctx context.Context, consumed *RefreshToken, ttl time.Duration) (*RefreshToken, error)
 	GetRefreshToken(ctx context.Context, tokenString string) (*RefreshToken, error)
+	ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	// ConsumeRefreshToken revokes the token only if it is still active, so of
+	// several concurrent calls with the same token exactly one succeeds. The
+	// others get storage.ErrNotFound, as do unknown tokens.
+	ConsumeRefreshToken(ctx context.Context, tokenString string) (*RefreshToken, error)
 	RevokeRefreshToken(ctx context.Context, tokenString string) error
+	// RevokeUserRefreshToken revokes the user's token with the ID, returning
+	// storage.ErrNotFound when the user has no such unrevoked token
+	RevokeUserRefreshToken(ctx context.Context, userID, id uuid.UUID) error
+	// RevokeUserRefreshTokens revokes all of the user's tokens, except those in
+	// the family exceptFamilyID when it is set
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID, exceptFamilyID *uuid.UUID) error
+	// DeleteExpiredRefreshTokens only deletes expired tokens. Revoked ones are
+	// kept until then so reuse of a rotated token is still recognised.
+	DeleteExpiredRefreshTokens(ctx context.Context, batchSize int) (int64, error)
 }
@@ -0,0 +1,237 @@
+package refreshtoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
+)
+
+type SQLiteRefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRefreshTokenRepository(db *sql.DB) *SQLiteRefreshTokenRepository {
+	return &SQLiteRefreshTokenRepository{db: db}
+}
+
+func (r *SQLiteRefreshTokenRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLiteRefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(ctx, userID, uuid.New(), ttl)
+}
+
+func (r *SQLiteRefreshTokenRepository) ReplaceRefreshToken(ctx context.Context, consumed *RefreshToken, ttl time.Duration) (*RefreshToken, error) {
+	return r.create(ctx, consumed.UserID, consumed.FamilyID, ttl)
+}
+
+func (r *SQLiteRefreshTokenRepository) create(ctx context.Context, userID, familyID uuid.UUID, ttl time.Duration) (*RefreshToken, error) {
+	rawToken := uuid.New().String()
+	hashedToken := utils.HashToken(rawToken)
+
+	now := time.Now().UTC()
+
+	token := &RefreshToken{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    userID,
+		FamilyID:  familyID,
+		Token:     rawToken,
+		ExpiresAt: now.Add(ttl),
+		Revoked:   false,
+	}
+
+	q := `
+		INSERT INTO refresh_tokens (id, created_at, user_id, family_id, token, expires_at, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, token.ID, token.CreatedAt, token.UserID, token.FamilyID, hashedToken, token.ExpiresAt, token.Revoked)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *SQLiteRefreshTokenRepository) GetRefreshToken(ctx context.Context, rawToken string) (*RefreshToken, error) {
+	var token RefreshToken
+
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		SELECT id, created_at, user_id, family_id, token, expires_at, revoked
+		FROM refresh_tokens
+		WHERE token = ?
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, q, hashedToken).Scan(
+		&token.ID,
+		&token.CreatedAt,
+		&token.UserID,
+		&token.FamilyID,
+		&token.Token,
+		&token.ExpiresAt,
+		&token.Revoked,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = rawToken
+
+	return &token, nil
+}
+
+func (r *SQLiteRefreshTokenRepository) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	q := `
+		SELECT id, created_at, user_id, family_id, expires_at, revoked
+		FROM refresh_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []RefreshToken
+
+	for rows.Next() {
+		var token RefreshToken
+
+		err := rows.Scan(
+			&token.ID,
+			&token.CreatedAt,
+			&token.UserID,
+			&token.FamilyID,
+			&token.ExpiresAt,
+			&token.Revoked,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *SQLiteRefreshTokenRepository) ConsumeRefreshToken(ctx context.Context, rawToken string) (*RefreshToken, error) {
+	var token RefreshToken
+
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE token = ? AND revoked = false
+		RETURNING id, created_at, user_id, family_id, expires_at, revoked
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, q, hashedToken).Scan(
+		&token.ID,
+		&token.CreatedAt,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.Revoked,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = rawToken
+
+	return &token, nil
+}
+
+func (r *SQLiteRefreshTokenRepository) RevokeRefreshToken(ctx context.Context, rawToken string) error {
+	hashedToken := utils.HashToken(rawToken)
+
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE token = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, hashedToken)
+
+	return err
+}
+
+func (r *SQLiteRefreshTokenRepository) RevokeUserRefreshToken(ctx context.Context, userID, id uuid.UUID) error {
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE id = ? AND user_id = ? AND revoked = false
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID, exceptFamilyID *uuid.UUID) error {
+	q := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE user_id = ? AND revoked = false AND (? IS NULL OR family_id <> ?)
+	`
+
+	var except any
+	if exceptFamilyID != nil {
+		except = *exceptFamilyID
+	}
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, userID, except, except)
+
+	return err
+}
+
+func (r *SQLiteRefreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, batchSize int) (int64, error) {
+	q := `
+		DELETE FROM refresh_tokens
+		WHERE id IN (
+			SELECT id
+			FROM refresh_tokens
+			WHERE expires_at < ?
+			LIMIT ?
+		)
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
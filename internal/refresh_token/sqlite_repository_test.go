@@ -0,0 +1,24 @@
+package refreshtoken_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/refresh_token/refreshtokentest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestSQLiteRefreshTokenRepository(t *testing.T) {
+	db := databasetest.NewSQLite(t)
+	users := user.NewSQLiteUserRepository(db)
+
+	refreshtokentest.RunRepositoryTests(
+		t,
+		func(t *testing.T) refreshtoken.Repository {
+			return refreshtoken.NewSQLiteRefreshTokenRepository(db)
+		},
+		usertest.NewUserID(users),
+	)
+}
@@ -2,18 +2,57 @@ package routes
 
 import (
 	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
 	"github.com/joacolabadie/go-auth-template-v2/internal/middleware"
+	"github.com/joacolabadie/go-auth-template-v2/internal/openapi"
+	personalaccesstoken "github.com/joacolabadie/go-auth-template-v2/internal/personal_access_token"
 	"github.com/joacolabadie/go-auth-template-v2/internal/user"
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterRoutes(e *echo.Echo, authService *auth.Service, authHandler *auth.Handler, userHandler *user.Handler) {
+func RegisterRoutes(e *echo.Echo, authService *auth.Service, tokenService *auth.PersonalAccessTokenService, authHandler *auth.Handler, magicLinkHandler *auth.MagicLinkHandler, otpHandler *auth.OTPHandler, emailChangeHandler *auth.EmailChangeHandler, accountHandler *auth.AccountHandler, usernameHandler *auth.UsernameHandler, personalAccessTokenHandler *auth.PersonalAccessTokenHandler, sessionHandler *auth.SessionHandler, userHandler *user.Handler, healthHandler *health.Handler) {
+	// Probe and documentation routes
+	e.GET("/healthz", healthHandler.Liveness)
+	e.GET("/readyz", healthHandler.Readiness)
+	e.GET("/openapi.json", openapi.Handler)
+	e.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Public routes
 	e.POST("/api/auth/register", authHandler.Register)
 	e.POST("/api/auth/login", authHandler.Login)
 	e.POST("/api/auth/refresh", authHandler.RefreshToken)
+	e.POST("/api/auth/introspect", authHandler.Introspect)
+	e.POST("/api/auth/magic-link", magicLinkHandler.RequestMagicLink)
+	e.GET("/api/auth/magic-link/verify", magicLinkHandler.ConfirmMagicLink)
+	e.POST("/api/auth/magic-link/verify", magicLinkHandler.VerifyMagicLink)
+	e.POST("/api/auth/otp/email", otpHandler.StartEmailLogin)
+	e.POST("/api/auth/otp/verify", otpHandler.VerifyEmailLogin)
+	e.POST("/api/auth/mfa/verify", otpHandler.VerifyMFA)
+	e.GET("/api/user/email/confirm", emailChangeHandler.ConfirmEmailChange)
+	e.POST("/api/user/email/confirm", emailChangeHandler.ConfirmEmailChange)
+	e.GET("/api/user/email/cancel", emailChangeHandler.CancelEmailChange)
+	e.POST("/api/user/email/cancel", emailChangeHandler.CancelEmailChange)
+	e.GET("/api/user/username/available", usernameHandler.CheckAvailability)
+	e.GET("/api/users/:username", usernameHandler.GetUser)
+
+	// Protected routes that also accept a personal access token with the scope
+	e.GET("/api/user/profile", userHandler.Profile, middleware.AccessTokenMiddleware(authService, tokenService, personalaccesstoken.ScopeProfileRead))
+	e.PATCH("/api/user/profile", userHandler.UpdateProfile, middleware.AccessTokenMiddleware(authService, tokenService, personalaccesstoken.ScopeProfileWrite))
+	e.PUT("/api/user/username", usernameHandler.ChangeUsername, middleware.AccessTokenMiddleware(authService, tokenService, personalaccesstoken.ScopeProfileWrite))
+	e.GET("/api/user/export", accountHandler.Export, middleware.AccessTokenMiddleware(authService, tokenService, personalaccesstoken.ScopeAccountExport))
 
 	// Protected routes
-	e.GET("/api/user/profile", userHandler.Profile, middleware.JWTMiddleware(authService))
 	e.POST("/api/auth/logout", authHandler.Logout, middleware.JWTMiddleware(authService))
+	e.POST("/api/auth/otp/step-up", otpHandler.StartStepUp, middleware.JWTMiddleware(authService))
+	e.POST("/api/user/phone", otpHandler.StartPhoneVerification, middleware.JWTMiddleware(authService))
+	e.POST("/api/user/phone/verify", otpHandler.VerifyPhone, middleware.JWTMiddleware(authService))
+	e.POST("/api/user/email", emailChangeHandler.RequestEmailChange, middleware.JWTMiddleware(authService))
+	e.DELETE("/api/user", accountHandler.DeleteAccount, middleware.JWTMiddleware(authService))
+	e.POST("/api/user/restore", accountHandler.CancelDeletion, middleware.JWTMiddleware(authService))
+	e.GET("/api/user/tokens", personalAccessTokenHandler.ListTokens, middleware.JWTMiddleware(authService))
+	e.POST("/api/user/tokens", personalAccessTokenHandler.CreateToken, middleware.JWTMiddleware(authService))
+	e.DELETE("/api/user/tokens/:id", personalAccessTokenHandler.RevokeToken, middleware.JWTMiddleware(authService))
+	e.GET("/api/user/sessions", sessionHandler.ListSessions, middleware.JWTMiddleware(authService))
+	e.DELETE("/api/user/sessions", sessionHandler.RevokeOtherSessions, middleware.JWTMiddleware(authService))
+	e.DELETE("/api/user/sessions/:id", sessionHandler.RevokeSession, middleware.JWTMiddleware(authService))
 }
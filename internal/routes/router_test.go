@@ -0,0 +1,303 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/joacolabadie/go-auth-template-v2/internal/apierror"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
+	"github.com/joacolabadie/go-auth-template-v2/internal/openapi"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestServer registers the real routes with handlers that have no storage
+// behind them, which is enough to exercise everything up to the service call.
+func newTestServer() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = apierror.HTTPErrorHandler
+
+	authService := auth.NewService(nil, nil, nil, auth.NewHMACKey("test-secret"), "http://localhost", "test", time.Minute, time.Hour, nil)
+
+	RegisterRoutes(
+		e,
+		authService,
+		auth.NewPersonalAccessTokenService(nil, nil),
+		auth.NewHandler(authService, nil, "test", map[string]string{"api": "introspection-secret"}),
+		auth.NewMagicLinkHandler(nil, nil, "test"),
+		auth.NewOTPHandler(nil, "test"),
+		auth.NewEmailChangeHandler(nil),
+		auth.NewAccountHandler(nil, "test"),
+		auth.NewUsernameHandler(auth.NewUsernameService(nil, nil, time.Hour)),
+		auth.NewPersonalAccessTokenHandler(nil),
+		auth.NewSessionHandler(nil),
+		user.NewHandler(nil, user.NewProfileService(nil, nil, user.MetadataRules{})),
+		health.NewHandler(time.Second),
+	)
+
+	return e
+}
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(openapi.Spec)
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+
+	return doc
+}
+
+var echoParam = regexp.MustCompile(`:(\w+)`)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	doc := loadSpec(t)
+	e := newTestServer()
+
+	registered := map[string]bool{}
+
+	for _, route := range e.Routes() {
+		path := echoParam.ReplaceAllString(route.Path, "{$1}")
+		registered[route.Method+" "+path] = true
+
+		pathItem := doc.Paths.Find(path)
+		if pathItem == nil || pathItem.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is registered but missing from the spec", route.Method, path)
+		}
+	}
+
+	for path, pathItem := range doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			if !registered[method+" "+path] {
+				t.Errorf("%s %s is in the spec but not registered", method, path)
+			}
+		}
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	doc := loadSpec(t)
+	e := newTestServer()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		cookie *http.Cookie
+		status int
+		code   string
+	}{
+		{"liveness", http.MethodGet, "/healthz", "", nil, http.StatusOK, ""},
+		{"readiness", http.MethodGet, "/readyz", "", nil, http.StatusOK, ""},
+		{"openapi", http.MethodGet, "/openapi.json", "", nil, http.StatusOK, ""},
+		{"jwks", http.MethodGet, "/.well-known/jwks.json", "", nil, http.StatusOK, ""},
+		{"introspect without client credentials", http.MethodPost, "/api/auth/introspect", `{"token":"garbage"}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"register validation", http.MethodPost, "/api/auth/register", `{"email":"not-an-email","password":"123"}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"register malformed", http.MethodPost, "/api/auth/register", `{"email":`, nil, http.StatusBadRequest, "bad_request"},
+		{"login validation", http.MethodPost, "/api/auth/login", `{}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"login with email and username", http.MethodPost, "/api/auth/login", `{"email":"a@example.com","username":"alice","password":"123456"}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"refresh without cookie", http.MethodPost, "/api/auth/refresh", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"magic link validation", http.MethodPost, "/api/auth/magic-link", `{"email":""}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"magic link verify without token", http.MethodGet, "/api/auth/magic-link/verify?token=", "", nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"magic link verify without nonce", http.MethodPost, "/api/auth/magic-link/verify", `{"token":"abc"}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"email otp validation", http.MethodPost, "/api/auth/otp/email", `{}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"otp verify validation", http.MethodPost, "/api/auth/otp/verify", `{"code":"12"}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"mfa verify validation", http.MethodPost, "/api/auth/mfa/verify", `{}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"email confirm validation", http.MethodPost, "/api/user/email/confirm", `{}`, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"email cancel validation", http.MethodGet, "/api/user/email/cancel?token=", "", nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"username availability validation", http.MethodGet, "/api/user/username/available?username=", "", nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"username availability invalid", http.MethodGet, "/api/user/username/available?username=a%20b", "", nil, http.StatusOK, ""},
+		{"username availability reserved", http.MethodGet, "/api/user/username/available?username=admin", "", nil, http.StatusOK, ""},
+		{"change username without cookie", http.MethodPut, "/api/user/username", `{"username":"alice"}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"profile without cookie", http.MethodGet, "/api/user/profile", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"profile with invalid token", http.MethodGet, "/api/user/profile", "", &http.Cookie{Name: "access_token", Value: "garbage"}, http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"update profile without cookie", http.MethodPatch, "/api/user/profile", `{"display_name":"Ada"}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"logout without cookie", http.MethodPost, "/api/auth/logout", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"step-up without cookie", http.MethodPost, "/api/auth/otp/step-up", `{}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"phone without cookie", http.MethodPost, "/api/user/phone", `{}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"export without cookie", http.MethodGet, "/api/user/export", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"delete without cookie", http.MethodDelete, "/api/user", `{}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"list tokens without cookie", http.MethodGet, "/api/user/tokens", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"create token without cookie", http.MethodPost, "/api/user/tokens", `{"name":"ci","scopes":["profile:read"]}`, nil, http.StatusUnauthorized, "unauthorized"},
+		{"list sessions without cookie", http.MethodGet, "/api/user/sessions", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"revoke other sessions without cookie", http.MethodDelete, "/api/user/sessions", "", nil, http.StatusUnauthorized, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+
+			if tt.code != "" && !strings.Contains(rec.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("expected code %q, got %s", tt.code, rec.Body.String())
+			}
+
+			pathItem := doc.Paths.Find(req.URL.Path)
+			if pathItem == nil {
+				t.Fatalf("%s is missing from the spec", req.URL.Path)
+			}
+
+			input := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request: req,
+					Route: &routers.Route{
+						Spec:      doc,
+						Path:      req.URL.Path,
+						PathItem:  pathItem,
+						Method:    tt.method,
+						Operation: pathItem.GetOperation(tt.method),
+					},
+				},
+				Status:  rec.Code,
+				Header:  rec.Header(),
+				Body:    io.NopCloser(rec.Body),
+				Options: &openapi3filter.Options{IncludeResponseStatus: true},
+			}
+
+			if err := openapi3filter.ValidateResponse(context.Background(), input); err != nil {
+				t.Errorf("response does not match the spec: %v", err)
+			}
+		})
+	}
+}
+
+func TestBearerTokens(t *testing.T) {
+	e := newTestServer()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+		code          string
+	}{
+		{"not a bearer token", "/api/user/profile", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "unauthorized"},
+		{"empty bearer token", "/api/user/profile", "Bearer ", http.StatusUnauthorized, "unauthorized"},
+		{"JWT as bearer token", "/api/user/profile", "Bearer eyJhbGciOiJIUzI1NiJ9.e30.sig", http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"bearer token on a cookie-only route", "/api/user/tokens", "Bearer gat_0123456789abcdef", http.StatusUnauthorized, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+
+			if !strings.Contains(rec.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("expected code %q, got %s", tt.code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestIntrospectionRequiresClientCredentials(t *testing.T) {
+	e := newTestServer()
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		body     string
+		status   int
+		code     string
+	}{
+		{"valid client, invalid token", "api", "introspection-secret", `{"token":"garbage"}`, http.StatusOK, ""},
+		{"valid client, validation", "api", "introspection-secret", `{}`, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"wrong secret", "api", "wrong", `{"token":"garbage"}`, http.StatusUnauthorized, "unauthorized"},
+		{"unknown client", "other", "introspection-secret", `{"token":"garbage"}`, http.StatusUnauthorized, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/introspect", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.SetBasicAuth(tt.clientID, tt.secret)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+
+			if tt.code != "" && !strings.Contains(rec.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("expected code %q, got %s", tt.code, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && !strings.Contains(rec.Body.String(), `"active":false`) {
+				t.Errorf("expected an inactive token, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMagicLinkIsOnlySpentByPost(t *testing.T) {
+	e := newTestServer()
+
+	// The handler has no service behind it, so a GET that tried to spend the
+	// link would fail instead of rendering the page
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/magic-link/verify?token=a%22b", nil)
+	req.AddCookie(&http.Cookie{Name: "magic_link_nonce", Value: "nonce"})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(got, echo.MIMETextHTML) {
+		t.Errorf("expected an HTML page, got %q", got)
+	}
+	if rec.Header().Get("Set-Cookie") != "" {
+		t.Errorf("expected no cookies, got %q", rec.Header().Get("Set-Cookie"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `method="post"`) || !strings.Contains(body, `value="a&#34;b"`) {
+		t.Errorf("expected a form posting the escaped token, got %s", body)
+	}
+
+	// The page submits a form, which the POST accepts like JSON
+	req = httptest.NewRequest(http.MethodPost, "/api/auth/magic-link/verify", strings.NewReader("token=abc"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a form without the nonce cookie, got %d: %s", http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type HTTPSMSSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewHTTPSMSSender(url, token string) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type Message struct {
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+func (s *HTTPSMSSender) Send(ctx context.Context, to, body string) error {
+	payload, err := json.Marshal(Message{To: to, Body: body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms provider returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
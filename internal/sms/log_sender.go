@@ -0,0 +1,51 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/logging"
+)
+
+type LogSMSSender struct {
+	path      string
+	logBodies bool
+	mu        sync.Mutex
+}
+
+// NewLogSMSSender appends messages to the file at path, or writes them to the
+// standard logger when path is empty. The logger only gets the body, at debug
+// level, when logBodies is set since it holds the verification code.
+func NewLogSMSSender(path string, logBodies bool) *LogSMSSender {
+	return &LogSMSSender{path: path, logBodies: logBodies}
+}
+
+func (s *LogSMSSender) Send(ctx context.Context, to, body string) error {
+	if s.path == "" {
+		logger := logging.FromRequestContext(ctx)
+
+		logger.InfoContext(ctx, "Sending SMS", "to", to)
+
+		if s.logBodies {
+			logger.DebugContext(ctx, "SMS body", "to", to, "body", body)
+		}
+
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), to, body)
+
+	return err
+}
@@ -0,0 +1,7 @@
+package sms
+
+import "context"
+
+type SMSSender interface {
+	Send(ctx context.Context, to, body string) error
+}
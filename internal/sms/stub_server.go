@@ -0,0 +1,57 @@
+package sms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// StubServer is a local SMS provider that records every message posted to it
+// by HTTPSMSSender. It is meant for tests and local development.
+type StubServer struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewStubServer() *StubServer {
+	s := &StubServer{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+func (s *StubServer) URL() string {
+	return s.server.URL
+}
+
+func (s *StubServer) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *StubServer) Close() {
+	s.server.Close()
+}
+
+func (s *StubServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,9 @@
+// Package storage holds the errors shared by every repository implementation,
+// so callers can handle them without knowing which database is behind it.
+package storage
+
+import "errors"
+
+// ErrNotFound is returned when a lookup, or an update or delete that must
+// touch a row, finds nothing.
+var ErrNotFound = errors.New("record not found")
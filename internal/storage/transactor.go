@@ -0,0 +1,18 @@
+package storage
+
+import "context"
+
+// Transactor runs fn as a single unit of work. Repository calls made with the
+// context handed to fn take part in the transaction, which commits only when
+// fn returns nil. Calls made while a transaction is already open join it.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NopTransactor runs fn directly. It backs the in-memory repositories, whose
+// operations are individually atomic but can't be rolled back.
+type NopTransactor struct{}
+
+func (NopTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
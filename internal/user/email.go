@@ -0,0 +1,80 @@
+package user
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Providers whose mailboxes ignore "+tag" suffixes, and for some dots, in the
+// local part. They only affect EmailKey, and only when enabled.
+var emailProviders = map[string]struct {
+	domain     string
+	ignoreDots bool
+}{
+	"gmail.com":      {"gmail.com", true},
+	"googlemail.com": {"gmail.com", true},
+	"outlook.com":    {"outlook.com", false},
+	"hotmail.com":    {"hotmail.com", false},
+	"live.com":       {"live.com", false},
+	"icloud.com":     {"icloud.com", false},
+	"me.com":         {"icloud.com", false},
+	"fastmail.com":   {"fastmail.com", false},
+	"proton.me":      {"proton.me", false},
+	"protonmail.com": {"proton.me", false},
+}
+
+var providerRules atomic.Bool
+
+// SetEmailProviderRules makes EmailKey apply provider-specific dot and plus
+// rules, so that j.doe+news@gmail.com and jdoe@gmail.com are one account.
+// Existing keys have to be recomputed after turning it on.
+func SetEmailProviderRules(enabled bool) {
+	providerRules.Store(enabled)
+}
+
+// NormalizeEmail returns the form an email is stored and displayed in: trimmed,
+// NFC-normalized and with a lowercase domain. The local part keeps its case.
+func NormalizeEmail(email string) string {
+	email = norm.NFC.String(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	return email[:at] + strings.ToLower(email[at:])
+}
+
+// EmailKey returns the case-insensitive identity of an email, which users are
+// looked up by and which is unique across users.
+func EmailKey(email string) string {
+	key := strings.ToLower(NormalizeEmail(email))
+
+	if !providerRules.Load() {
+		return key
+	}
+
+	at := strings.LastIndex(key, "@")
+	if at < 0 {
+		return key
+	}
+
+	local, domain := key[:at], key[at+1:]
+
+	provider, ok := emailProviders[domain]
+	if !ok {
+		return key
+	}
+
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+
+	if provider.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+
+	return local + "@" + provider.domain
+}
@@ -0,0 +1,51 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"bob@example.com", "bob@example.com"},
+		{"  Bob@Example.COM\n", "Bob@example.com"},
+		// A decomposed é is composed
+		{"Jose\u0301@example.com", "Jos\u00e9@example.com"},
+		{"no-at-sign", "no-at-sign"},
+	}
+
+	for _, tt := range tests {
+		if got := user.NormalizeEmail(tt.email); got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestEmailKey(t *testing.T) {
+	tests := []struct {
+		email         string
+		providerRules bool
+		want          string
+	}{
+		{"Bob@Example.com", false, "bob@example.com"},
+		{"J.Doe+news@Gmail.com", false, "j.doe+news@gmail.com"},
+		{"J.Doe+news@Gmail.com", true, "jdoe@gmail.com"},
+		{"j.doe@googlemail.com", true, "jdoe@gmail.com"},
+		{"j.doe+news@outlook.com", true, "j.doe@outlook.com"},
+		{"j.doe+news@example.com", true, "j.doe+news@example.com"},
+	}
+
+	t.Cleanup(func() { user.SetEmailProviderRules(false) })
+
+	for _, tt := range tests {
+		user.SetEmailProviderRules(tt.providerRules)
+
+		if got := user.EmailKey(tt.email); got != tt.want {
+			t.Errorf("EmailKey(%q) with provider rules %v = %q, want %q", tt.email, tt.providerRules, got, tt.want)
+		}
+	}
+}
@@ -1,15 +1,55 @@
 package user
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID           uuid.UUID  `json:"id"`
-	CreatedAt    time.Time  `json:"created_at"`
-	Email        string     `json:"email"`
-	PasswordHash string     `json:"-"`
-	LastLogin    *time.Time `json:"last_login"`
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	Email               string     `json:"email"`
+	Username            *string    `json:"username"`
+	UsernameChangedAt   *time.Time `json:"username_changed_at"`
+	DisplayName         *string    `json:"display_name"`
+	AvatarURL           *string    `json:"avatar_url"`
+	Locale              *string    `json:"locale"`
+	Timezone            *string    `json:"timezone"`
+	PasswordHash        string     `json:"-"`
+	LastLogin           *time.Time `json:"last_login"`
+	PhoneNumber         *string    `json:"phone_number"`
+	PhoneVerified       bool       `json:"phone_verified"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	LockedAt            *time.Time `json:"locked_at"`
+
+	// UserMetadata is editable by the user, AppMetadata only by operators.
+	// Both are JSON objects.
+	UserMetadata json.RawMessage `json:"user_metadata"`
+	AppMetadata  json.RawMessage `json:"app_metadata"`
+}
+
+// PreviousUsername is a name the user moved away from and still holds
+type PreviousUsername struct {
+	Username  string    `json:"username"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UserEmail is the part of a user "migrate check-emails" reads. Its columns
+// exist in every schema version, so it can be listed before migrating.
+type UserEmail struct {
+	ID        uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	LastLogin *time.Time
+}
+
+// Profile holds the fields users may edit themselves
+type Profile struct {
+	DisplayName  *string
+	AvatarURL    *string
+	Locale       *string
+	Timezone     *string
+	UserMetadata json.RawMessage
 }
@@ -1,49 +1,114 @@
 package user
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/utils"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	repo Repository
+	repo           Repository
+	profileService *ProfileService
 }
 
-func NewHandler(repo Repository) *Handler {
+func NewHandler(repo Repository, profileService *ProfileService) *Handler {
 	return &Handler{
-		repo: repo,
+		repo:           repo,
+		profileService: profileService,
 	}
 }
 
 type ProfileResponse struct {
-	ID        string     `json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	Email     string     `json:"email"`
-	LastLogin *time.Time `json:"last_login,omitempty"`
+	ID                  string          `json:"id"`
+	CreatedAt           time.Time       `json:"created_at"`
+	Email               string          `json:"email"`
+	Username            *string         `json:"username,omitempty"`
+	DisplayName         *string         `json:"display_name,omitempty"`
+	AvatarURL           *string         `json:"avatar_url,omitempty"`
+	Locale              *string         `json:"locale,omitempty"`
+	Timezone            *string         `json:"timezone,omitempty"`
+	UserMetadata        json.RawMessage `json:"user_metadata"`
+	AppMetadata         json.RawMessage `json:"app_metadata"`
+	LastLogin           *time.Time      `json:"last_login,omitempty"`
+	PhoneNumber         *string         `json:"phone_number,omitempty"`
+	PhoneVerified       bool            `json:"phone_verified"`
+	DeletionScheduledAt *time.Time      `json:"deletion_scheduled_at,omitempty"`
 }
 
 func (h *Handler) Profile(c echo.Context) error {
 	userID, ok := c.Get("userID").(uuid.UUID)
 	if !ok {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+		return echo.ErrUnauthorized
 	}
 
 	user, err := h.repo.GetUserByID(c.Request().Context(), userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": "Failed to retrieve user profile",
-		})
+		return err
 	}
 
-	response := ProfileResponse{
-		ID:        user.ID.String(),
-		CreatedAt: user.CreatedAt,
-		Email:     user.Email,
-		LastLogin: user.LastLogin,
+	return c.JSON(http.StatusOK, newProfileResponse(user))
+}
+
+// UpdateProfileRequest only changes the fields that are present. An empty
+// string clears a field, hence the len=0 alternatives, and user_metadata is
+// merged into the stored metadata as a JSON merge patch.
+type UpdateProfileRequest struct {
+	DisplayName  *string         `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL    *string         `json:"avatar_url" validate:"omitempty,http_url|len=0,max=2048"`
+	Locale       *string         `json:"locale" validate:"omitempty,bcp47_language_tag|len=0"`
+	Timezone     *string         `json:"timezone" validate:"omitempty,timezone|len=0"`
+	UserMetadata json.RawMessage `json:"user_metadata"`
+}
+
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var req UpdateProfileRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, response)
+	if err := utils.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	updated, err := h.profileService.UpdateProfile(c.Request().Context(), userID, ProfileUpdate{
+		DisplayName:  req.DisplayName,
+		AvatarURL:    req.AvatarURL,
+		Locale:       req.Locale,
+		Timezone:     req.Timezone,
+		UserMetadata: req.UserMetadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newProfileResponse(updated))
+}
+
+func newProfileResponse(user *User) ProfileResponse {
+	return ProfileResponse{
+		ID:                  user.ID.String(),
+		CreatedAt:           user.CreatedAt,
+		Email:               user.Email,
+		Username:            user.Username,
+		DisplayName:         user.DisplayName,
+		AvatarURL:           user.AvatarURL,
+		Locale:              user.Locale,
+		Timezone:            user.Timezone,
+		UserMetadata:        user.UserMetadata,
+		AppMetadata:         user.AppMetadata,
+		LastLogin:           user.LastLogin,
+		PhoneNumber:         user.PhoneNumber,
+		PhoneVerified:       user.PhoneVerified,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+	}
 }
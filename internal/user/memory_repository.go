@@ -0,0 +1,417 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+)
+
+// MemoryUserRepository keeps users in a map. It follows the Postgres
+// repository's semantics and is meant for tests and local experiments; data
+// owned by other repositories is not cascaded on delete.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]*User
+	// usernameHistory maps the key of a previous username to its holder
+	usernameHistory map[string]previousUsername
+}
+
+type previousUsername struct {
+	userID uuid.UUID
+	PreviousUsername
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:           make(map[uuid.UUID]*User),
+		usernameHistory: make(map[string]previousUsername),
+	}
+}
+
+func (r *MemoryUserRepository) CreateUser(ctx context.Context, email, passwordHash string) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findByEmail(email) != nil {
+		return uuid.Nil, ErrEmailInUse
+	}
+
+	user := &User{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		Email:        NormalizeEmail(email),
+		PasswordHash: passwordHash,
+		UserMetadata: json.RawMessage(`{}`),
+		AppMetadata:  json.RawMessage(`{}`),
+	}
+	r.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (r *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.findByEmail(email)
+	if user == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+// GetUserByIDForUpdate can't lock anything since the memory repository has no
+// transactions, so concurrent read-modify-writes may still lose updates
+func (r *MemoryUserRepository) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (*User, error) {
+	return r.GetUserByID(ctx, id)
+}
+
+func (r *MemoryUserRepository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.findByUsername(username)
+	if user == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) GetUserByPreviousUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[r.usernameHistory[UsernameKey(username)].userID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) ListPreviousUsernames(ctx context.Context, id uuid.UUID) ([]PreviousUsername, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var usernames []PreviousUsername
+	for _, previous := range r.usernameHistory {
+		if previous.userID == id {
+			usernames = append(usernames, previous.PreviousUsername)
+		}
+	}
+
+	sort.Slice(usernames, func(i, j int) bool {
+		if !usernames[i].ChangedAt.Equal(usernames[j].ChangedAt) {
+			return usernames[i].ChangedAt.After(usernames[j].ChangedAt)
+		}
+		return UsernameKey(usernames[i].Username) < UsernameKey(usernames[j].Username)
+	})
+
+	return usernames, nil
+}
+
+func (r *MemoryUserRepository) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		all = append(all, *copyUser(user))
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.Before(all[j].CreatedAt)
+	})
+
+	if offset >= len(all) {
+		return nil, nil
+	}
+	all = all[offset:]
+
+	if limit < len(all) {
+		all = all[:limit]
+	}
+
+	return all, nil
+}
+
+func (r *MemoryUserRepository) ListUserEmails(ctx context.Context, limit, offset int) ([]UserEmail, error) {
+	users, err := r.ListUsers(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []UserEmail
+	for _, user := range users {
+		emails = append(emails, UserEmail{
+			ID:        user.ID,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+			LastLogin: user.LastLogin,
+		})
+	}
+
+	return emails, nil
+}
+
+func (r *MemoryUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *User) {
+		now := time.Now()
+		user.LastLogin = &now
+	})
+}
+
+func (r *MemoryUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing := r.findByEmail(email); existing != nil && existing.ID != id {
+		return ErrEmailInUse
+	}
+
+	if user, ok := r.users[id]; ok {
+		user.Email = NormalizeEmail(email)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return r.update(id, func(user *User) {
+		user.PasswordHash = passwordHash
+	})
+}
+
+func (r *MemoryUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profile Profile) error {
+	return r.update(id, func(user *User) {
+		user.DisplayName = profile.DisplayName
+		user.AvatarURL = profile.AvatarURL
+		user.Locale = profile.Locale
+		user.Timezone = profile.Timezone
+		user.UserMetadata = bytes.Clone(profile.UserMetadata)
+	})
+}
+
+func (r *MemoryUserRepository) UpdateAppMetadata(ctx context.Context, id uuid.UUID, metadata json.RawMessage) error {
+	return r.update(id, func(user *User) {
+		user.AppMetadata = bytes.Clone(metadata)
+	})
+}
+
+func (r *MemoryUserRepository) UpdateUsername(ctx context.Context, id uuid.UUID, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	key := UsernameKey(username)
+
+	if existing := r.findByUsername(username); existing != nil && existing.ID != id {
+		return ErrUsernameTaken
+	}
+	if previous, ok := r.usernameHistory[key]; ok && previous.userID != id {
+		return ErrUsernameTaken
+	}
+
+	delete(r.usernameHistory, key)
+
+	now := time.Now()
+
+	if user.Username != nil && UsernameKey(*user.Username) != key {
+		r.usernameHistory[UsernameKey(*user.Username)] = previousUsername{
+			userID:           id,
+			PreviousUsername: PreviousUsername{Username: *user.Username, ChangedAt: now},
+		}
+	}
+
+	normalized := NormalizeUsername(username)
+	user.Username = &normalized
+	user.UsernameChangedAt = &now
+
+	return nil
+}
+
+func (r *MemoryUserRepository) LockUser(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *User) {
+		if user.LockedAt == nil {
+			now := time.Now()
+			user.LockedAt = &now
+		}
+	})
+}
+
+func (r *MemoryUserRepository) UnlockUser(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *User) {
+		user.LockedAt = nil
+	})
+}
+
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return storage.ErrNotFound
+	}
+
+	delete(r.users, id)
+	r.deleteUsernameHistory(id)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) SetVerifiedPhoneNumber(ctx context.Context, id uuid.UUID, phoneNumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	user.PhoneNumber = &phoneNumber
+	user.PhoneVerified = true
+
+	return nil
+}
+
+func (r *MemoryUserRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.update(id, func(user *User) {
+		user.DeletionScheduledAt = &at
+	})
+}
+
+func (r *MemoryUserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *User) {
+		user.DeletionScheduledAt = nil
+	})
+}
+
+func (r *MemoryUserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+
+	for id, user := range r.users {
+		if purged >= int64(batchSize) {
+			break
+		}
+
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(before) {
+			delete(r.users, id)
+			r.deleteUsernameHistory(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// update applies fn to the user if it exists. Like an UPDATE matching no
+// rows, a missing user is not an error.
+func (r *MemoryUserRepository) update(id uuid.UUID, fn func(user *User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		fn(user)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) findByEmail(email string) *User {
+	key := EmailKey(email)
+
+	for _, user := range r.users {
+		if EmailKey(user.Email) == key {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) findByUsername(username string) *User {
+	key := UsernameKey(username)
+
+	for _, user := range r.users {
+		if user.Username != nil && UsernameKey(*user.Username) == key {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) deleteUsernameHistory(id uuid.UUID) {
+	for key, previous := range r.usernameHistory {
+		if previous.userID == id {
+			delete(r.usernameHistory, key)
+		}
+	}
+}
+
+// copyUser returns a deep copy so callers can't mutate stored state
+func copyUser(user *User) *User {
+	copied := *user
+
+	if user.Username != nil {
+		username := *user.Username
+		copied.Username = &username
+	}
+	if user.UsernameChangedAt != nil {
+		usernameChangedAt := *user.UsernameChangedAt
+		copied.UsernameChangedAt = &usernameChangedAt
+	}
+	for _, field := range []**string{&copied.DisplayName, &copied.AvatarURL, &copied.Locale, &copied.Timezone} {
+		if *field != nil {
+			value := **field
+			*field = &value
+		}
+	}
+	copied.UserMetadata = bytes.Clone(user.UserMetadata)
+	copied.AppMetadata = bytes.Clone(user.AppMetadata)
+	if user.LastLogin != nil {
+		lastLogin := *user.LastLogin
+		copied.LastLogin = &lastLogin
+	}
+	if user.PhoneNumber != nil {
+		phoneNumber := *user.PhoneNumber
+		copied.PhoneNumber = &phoneNumber
+	}
+	if user.DeletionScheduledAt != nil {
+		deletionScheduledAt := *user.DeletionScheduledAt
+		copied.DeletionScheduledAt = &deletionScheduledAt
+	}
+	if user.LockedAt != nil {
+		lockedAt := *user.LockedAt
+		copied.LockedAt = &lockedAt
+	}
+
+	return &copied
+}
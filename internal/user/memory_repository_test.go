@@ -0,0 +1,14 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	usertest.RunRepositoryTests(t, func(t *testing.T) user.Repository {
+		return user.NewMemoryUserRepository()
+	})
+}
@@ -0,0 +1,157 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	maxMetadataDepth     = 5
+	maxMetadataKeyLength = 64
+)
+
+var (
+	// ErrMetadataInvalid is wrapped with the rule the metadata breaks
+	ErrMetadataInvalid  = errors.New("invalid metadata")
+	ErrMetadataTooLarge = errors.New("metadata is too large")
+)
+
+// MetadataRules limit what a metadata field may hold. Metadata is always a
+// JSON object of bounded depth; Schema, when set, is an OpenAPI schema object
+// it must also match.
+type MetadataRules struct {
+	MaxBytes int
+	Schema   *openapi3.Schema
+}
+
+// LoadMetadataSchema reads a JSON-encoded OpenAPI schema object from path
+func LoadMetadataSchema(path string) (*openapi3.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema openapi3.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata schema %s: %w", path, err)
+	}
+
+	if err := schema.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid metadata schema %s: %w", path, err)
+	}
+
+	return &schema, nil
+}
+
+// Patch applies patch to current as a JSON merge patch (RFC 7396), so keys set
+// to null are removed and objects are merged recursively, then checks the
+// result against the rules. A null patch clears the metadata.
+func (r MetadataRules) Patch(current, patch json.RawMessage) (json.RawMessage, error) {
+	var target any = map[string]any{}
+	if len(current) > 0 {
+		if err := decodeMetadata(current, &target); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes any
+	if err := decodeMetadata(patch, &changes); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMetadataInvalid, err)
+	}
+
+	merged := mergePatch(target, changes)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+
+	object, ok := merged.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: must be an object", ErrMetadataInvalid)
+	}
+
+	if err := checkMetadataValue(object, 1); err != nil {
+		return nil, err
+	}
+
+	if r.Schema != nil {
+		if err := r.Schema.VisitJSON(object); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMetadataInvalid, err)
+		}
+	}
+
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.MaxBytes > 0 && len(encoded) > r.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrMetadataTooLarge, len(encoded), r.MaxBytes)
+	}
+
+	return encoded, nil
+}
+
+// decodeMetadata keeps numbers as json.Number so large integers survive a
+// round trip unchanged
+func decodeMetadata(data json.RawMessage, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+func checkMetadataValue(value any, depth int) error {
+	if depth > maxMetadataDepth {
+		return fmt.Errorf("%w: nested deeper than %d levels", ErrMetadataInvalid, maxMetadataDepth)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for key, nested := range v {
+			if key == "" || len(key) > maxMetadataKeyLength {
+				return fmt.Errorf("%w: keys must be 1 to %d bytes", ErrMetadataInvalid, maxMetadataKeyLength)
+			}
+
+			if err := checkMetadataValue(nested, depth+1); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, nested := range v {
+			if err := checkMetadataValue(nested, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package user_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+func TestMetadataRulesPatch(t *testing.T) {
+	rules := user.MetadataRules{MaxBytes: 64}
+
+	tests := []struct {
+		current string
+		patch   string
+		want    string
+		err     error
+	}{
+		{`{}`, `{"theme":"dark"}`, `{"theme":"dark"}`, nil},
+		{`{"theme":"dark","lang":"en"}`, `{"theme":null}`, `{"lang":"en"}`, nil},
+		{`{"ui":{"theme":"dark","compact":true}}`, `{"ui":{"compact":false}}`, `{"ui":{"compact":false,"theme":"dark"}}`, nil},
+		{`{"id":9007199254740993}`, `{}`, `{"id":9007199254740993}`, nil},
+		{`{"theme":"dark"}`, `null`, `{}`, nil},
+		{`{}`, `[1,2]`, ``, user.ErrMetadataInvalid},
+		{`{}`, `{"a":{"b":{"c":{"d":{"e":1}}}}}`, ``, user.ErrMetadataInvalid},
+		{`{}`, `{"` + strings.Repeat("k", 65) + `":1}`, ``, user.ErrMetadataInvalid},
+		{`{}`, `{"bio":"` + strings.Repeat("x", 64) + `"}`, ``, user.ErrMetadataTooLarge},
+	}
+
+	for _, tt := range tests {
+		got, err := rules.Patch(json.RawMessage(tt.current), json.RawMessage(tt.patch))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Patch(%s, %s) error = %v, want %v", tt.current, tt.patch, err, tt.err)
+			continue
+		}
+		if err == nil && string(got) != tt.want {
+			t.Errorf("Patch(%s, %s) = %s, want %s", tt.current, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataRulesSchema(t *testing.T) {
+	rules := user.MetadataRules{
+		Schema: openapi3.NewObjectSchema().WithProperty("theme", openapi3.NewStringSchema().WithEnum("light", "dark")),
+	}
+
+	if _, err := rules.Patch(nil, json.RawMessage(`{"theme":"dark"}`)); err != nil {
+		t.Errorf("Patch with a matching value: %v", err)
+	}
+	if _, err := rules.Patch(nil, json.RawMessage(`{"theme":"blue"}`)); !errors.Is(err, user.ErrMetadataInvalid) {
+		t.Errorf("Patch with a value outside the schema error = %v, want user.ErrMetadataInvalid", err)
+	}
+}
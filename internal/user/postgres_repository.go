@@ -2,11 +2,16 @@ package user
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
 )
 
 type PostgresUserRepository struct {
@@ -17,21 +22,28 @@ func NewPostgresUserRepository(db *pgxpool.Pool) *PostgresUserRepository {
 	return &PostgresUserRepository{db: db}
 }
 
+func (r *PostgresUserRepository) conn(ctx context.Context) database.PostgresQuerier {
+	return database.PostgresConn(ctx, r.db)
+}
+
 func (r *PostgresUserRepository) CreateUser(ctx context.Context, email, passwordHash string) (uuid.UUID, error) {
 	user := &User{
-		Email:        email,
+		Email:        NormalizeEmail(email),
 		PasswordHash: passwordHash,
 	}
 
 	var id uuid.UUID
 
 	q := `
-		INSERT INTO users (email, password_hash)
-		VALUES ($1, $2)
+		INSERT INTO users (email, email_key, password_hash)
+		VALUES ($1, $2, $3)
 		RETURNING id
 	`
 
-	err := r.db.QueryRow(ctx, q, user.Email, user.PasswordHash).Scan(&id)
+	err := r.conn(ctx).QueryRow(ctx, q, user.Email, EmailKey(email), user.PasswordHash).Scan(&id)
+	if database.IsUniqueViolation(err) {
+		return uuid.Nil, ErrEmailInUse
+	}
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -41,57 +53,228 @@ func (r *PostgresUserRepository) CreateUser(ctx context.Context, email, password
 
 func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	q := `
-		SELECT id, created_at, email, password_hash, last_login
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
 		FROM users
-		WHERE email = $1
+		WHERE email_key = $1
 	`
 
-	var user User
-	var lastLogin pgtype.Timestamp
+	return scanUser(r.conn(ctx).QueryRow(ctx, q, EmailKey(email)))
+}
 
-	err := r.db.QueryRow(ctx, q, email).Scan(
-		&user.ID,
-		&user.CreatedAt,
-		&user.Email,
-		&user.PasswordHash,
-		&lastLogin,
-	)
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE id = $1
+	`
+
+	return scanUser(r.conn(ctx).QueryRow(ctx, q, id))
+}
+
+func (r *PostgresUserRepository) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	return scanUser(r.conn(ctx).QueryRow(ctx, q, id))
+}
+
+func (r *PostgresUserRepository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE username_key = $1
+	`
+
+	return scanUser(r.conn(ctx).QueryRow(ctx, q, UsernameKey(username)))
+}
+
+func (r *PostgresUserRepository) GetUserByPreviousUsername(ctx context.Context, username string) (*User, error) {
+	q := `
+		SELECT u.id, u.created_at, u.email, u.username, u.username_changed_at, u.display_name, u.avatar_url, u.locale, u.timezone, u.user_metadata, u.app_metadata, u.password_hash, u.last_login, u.phone_number, u.phone_verified, u.deletion_scheduled_at, u.locked_at
+		FROM username_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.username_key = $1
+	`
+
+	return scanUser(r.conn(ctx).QueryRow(ctx, q, UsernameKey(username)))
+}
+
+func (r *PostgresUserRepository) ListPreviousUsernames(ctx context.Context, id uuid.UUID) ([]PreviousUsername, error) {
+	q := `
+		SELECT username, created_at
+		FROM username_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, username_key
+	`
+
+	rows, err := r.conn(ctx).Query(ctx, q, id)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	if lastLogin.Valid {
-		user.LastLogin = &lastLogin.Time
+	var usernames []PreviousUsername
+
+	for rows.Next() {
+		var username PreviousUsername
+
+		if err := rows.Scan(&username.Username, &username.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
 	}
 
-	return &user, nil
+	return usernames, rows.Err()
 }
 
-func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+func (r *PostgresUserRepository) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
 	q := `
-		SELECT id, created_at, email, password_hash, last_login
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
 		FROM users
-		WHERE id = $1
+		ORDER BY created_at
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.conn(ctx).Query(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *PostgresUserRepository) ListUserEmails(ctx context.Context, limit, offset int) ([]UserEmail, error) {
+	q := `
+		SELECT id, email, created_at, last_login
+		FROM users
+		ORDER BY created_at
+		LIMIT $1 OFFSET $2
 	`
 
+	rows, err := r.conn(ctx).Query(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []UserEmail
+
+	for rows.Next() {
+		var email UserEmail
+		// Also scans the TIMESTAMP column schemas before migration 0012 have
+		var lastLogin pgtype.Timestamptz
+
+		if err := rows.Scan(&email.ID, &email.Email, &email.CreatedAt, &lastLogin); err != nil {
+			return nil, err
+		}
+
+		if lastLogin.Valid {
+			email.LastLogin = &lastLogin.Time
+		}
+
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+func scanUser(row pgx.Row) (*User, error) {
 	var user User
-	var lastLogin pgtype.Timestamp
+	var username pgtype.Text
+	var displayName, avatarURL, locale, timezone pgtype.Text
+	var userMetadata, appMetadata []byte
+	var usernameChangedAt pgtype.Timestamptz
+	var lastLogin pgtype.Timestamptz
+	var phoneNumber pgtype.Text
+	var deletionScheduledAt pgtype.Timestamptz
+	var lockedAt pgtype.Timestamptz
 
-	err := r.db.QueryRow(ctx, q, id).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.Email,
+		&username,
+		&usernameChangedAt,
+		&displayName,
+		&avatarURL,
+		&locale,
+		&timezone,
+		&userMetadata,
+		&appMetadata,
 		&user.PasswordHash,
 		&lastLogin,
+		&phoneNumber,
+		&user.PhoneVerified,
+		&deletionScheduledAt,
+		&lockedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if username.Valid {
+		user.Username = &username.String
+	}
+
+	if usernameChangedAt.Valid {
+		user.UsernameChangedAt = &usernameChangedAt.Time
+	}
+
+	if displayName.Valid {
+		user.DisplayName = &displayName.String
+	}
+
+	if avatarURL.Valid {
+		user.AvatarURL = &avatarURL.String
+	}
+
+	if locale.Valid {
+		user.Locale = &locale.String
+	}
+
+	if timezone.Valid {
+		user.Timezone = &timezone.String
+	}
+
+	user.UserMetadata = userMetadata
+	user.AppMetadata = appMetadata
+
 	if lastLogin.Valid {
 		user.LastLogin = &lastLogin.Time
 	}
 
+	if phoneNumber.Valid {
+		user.PhoneNumber = &phoneNumber.String
+	}
+
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+
+	if lockedAt.Valid {
+		user.LockedAt = &lockedAt.Time
+	}
+
 	return &user, nil
 }
 
@@ -102,7 +285,287 @@ func (r *PostgresUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UU
 		WHERE id = $2
 	`
 
-	_, err := r.db.Exec(ctx, q, time.Now(), id)
+	_, err := r.conn(ctx).Exec(ctx, q, time.Now(), id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	q := `
+		UPDATE users
+		SET email = $1, email_key = $2
+		WHERE id = $3
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, NormalizeEmail(email), EmailKey(email), id)
+	if database.IsUniqueViolation(err) {
+		return ErrEmailInUse
+	}
+
+	return err
+}
+
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	q := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, passwordHash, id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profile Profile) error {
+	q := `
+		UPDATE users
+		SET display_name = $1, avatar_url = $2, locale = $3, timezone = $4, user_metadata = $5
+		WHERE id = $6
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, profile.DisplayName, profile.AvatarURL, profile.Locale, profile.Timezone, profile.UserMetadata, id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateAppMetadata(ctx context.Context, id uuid.UUID, metadata json.RawMessage) error {
+	q := `
+		UPDATE users
+		SET app_metadata = $1
+		WHERE id = $2
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, metadata, id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateUsername(ctx context.Context, id uuid.UUID, username string) error {
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	key := UsernameKey(username)
+
+	var previous, previousKey pgtype.Text
+
+	err = tx.QueryRow(ctx, `SELECT username, username_key FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&previous, &previousKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// A name in the history belongs to whoever gave it up, who may take it back
+	var holder uuid.UUID
+
+	err = tx.QueryRow(ctx, `SELECT user_id FROM username_history WHERE username_key = $1`, key).Scan(&holder)
+	if err == nil && holder != id {
+		return ErrUsernameTaken
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM username_history WHERE username_key = $1`, key); err != nil {
+		return err
+	}
+
+	if previous.Valid && previousKey.String != key {
+		q := `
+			INSERT INTO username_history (username_key, username, user_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (username_key) DO UPDATE SET username = EXCLUDED.username, created_at = NOW()
+		`
+
+		if _, err := tx.Exec(ctx, q, previousKey.String, previous.String, id); err != nil {
+			return err
+		}
+	}
+
+	q := `
+		UPDATE users
+		SET username = $1, username_key = $2, username_changed_at = NOW()
+		WHERE id = $3
+	`
+
+	_, err = tx.Exec(ctx, q, NormalizeUsername(username), key, id)
+	if database.IsUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresUserRepository) LockUser(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET locked_at = $1
+		WHERE id = $2 AND locked_at IS NULL
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, time.Now(), id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) UnlockUser(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET locked_at = NULL
+		WHERE id = $1
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var email string
+
+	err = tx.QueryRow(ctx, `DELETE FROM users WHERE id = $1 RETURNING email`, id).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Magic links reference the email rather than the user, so the foreign key cascade doesn't reach them
+	if _, err := tx.Exec(ctx, `DELETE FROM magic_links WHERE email = $1`, email); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresUserRepository) SetVerifiedPhoneNumber(ctx context.Context, id uuid.UUID, phoneNumber string) error {
+	q := `
+		UPDATE users
+		SET phone_number = $1, phone_verified = true
+		WHERE id = $2
+	`
+
+	tag, err := r.conn(ctx).Exec(ctx, q, phoneNumber, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	q := `
+		UPDATE users
+		SET deletion_scheduled_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, at, id)
 
 	return err
 }
+
+func (r *PostgresUserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = $1
+	`
+
+	_, err := r.conn(ctx).Exec(ctx, q, id)
+
+	return err
+}
+
+func (r *PostgresUserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, email
+		FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+		ORDER BY deletion_scheduled_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, before, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []uuid.UUID
+	var emails []string
+
+	for rows.Next() {
+		var id uuid.UUID
+		var email string
+
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		ids = append(ids, id)
+		emails = append(emails, email)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// Remove everything the users own before the users themselves
+	cascades := []string{
+		`DELETE FROM refresh_tokens WHERE user_id = ANY($1)`,
+		`DELETE FROM otp_challenges WHERE user_id = ANY($1)`,
+		`DELETE FROM email_changes WHERE user_id = ANY($1)`,
+		`DELETE FROM username_history WHERE user_id = ANY($1)`,
+		`DELETE FROM personal_access_tokens WHERE user_id = ANY($1)`,
+	}
+	for _, q := range cascades {
+		if _, err := tx.Exec(ctx, q, ids); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM magic_links WHERE email = ANY($1)`, emails); err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
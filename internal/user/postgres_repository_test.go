@@ -0,0 +1,17 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestPostgresUserRepository(t *testing.T) {
+	pool := databasetest.NewPool(t)
+
+	usertest.RunRepositoryTests(t, func(t *testing.T) user.Repository {
+		return user.NewPostgresUserRepository(pool)
+	})
+}
@@ -0,0 +1,104 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"golang.org/x/text/language"
+)
+
+// ProfileUpdate only changes the fields that are set. An empty string clears
+// a field and UserMetadata is applied to the stored metadata as a JSON merge
+// patch.
+type ProfileUpdate struct {
+	DisplayName  *string
+	AvatarURL    *string
+	Locale       *string
+	Timezone     *string
+	UserMetadata json.RawMessage
+}
+
+type ProfileService struct {
+	tx            storage.Transactor
+	repo          Repository
+	metadataRules MetadataRules
+}
+
+func NewProfileService(tx storage.Transactor, repo Repository, metadataRules MetadataRules) *ProfileService {
+	return &ProfileService{
+		tx:            tx,
+		repo:          repo,
+		metadataRules: metadataRules,
+	}
+}
+
+// UpdateProfile applies update to the user's profile and returns the user as
+// stored afterwards.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error) {
+	var updated *User
+
+	// The user is locked while the update is merged with what's stored, so
+	// concurrent updates apply one after the other instead of overwriting each other
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		profile := Profile{
+			DisplayName:  patchField(user.DisplayName, update.DisplayName, strings.TrimSpace),
+			AvatarURL:    patchField(user.AvatarURL, update.AvatarURL, strings.TrimSpace),
+			Locale:       patchField(user.Locale, update.Locale, canonicalLocale),
+			Timezone:     patchField(user.Timezone, update.Timezone, strings.TrimSpace),
+			UserMetadata: user.UserMetadata,
+		}
+
+		if update.UserMetadata != nil {
+			profile.UserMetadata, err = s.metadataRules.Patch(user.UserMetadata, update.UserMetadata)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := s.repo.UpdateProfile(ctx, userID, profile); err != nil {
+			return err
+		}
+
+		updated, err = s.repo.GetUserByID(ctx, userID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// patchField keeps current when the update left the field out and clears it
+// when the update sent an empty string
+func patchField(current, requested *string, normalize func(string) string) *string {
+	if requested == nil {
+		return current
+	}
+
+	value := normalize(*requested)
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// canonicalLocale formats an already validated language tag, e.g. en-us as en-US
+func canonicalLocale(locale string) string {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return locale
+	}
+
+	return tag.String()
+}
@@ -0,0 +1,108 @@
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+// The memory repository is left out since it has no transactions to lock in
+var profileBackends = []struct {
+	name    string
+	newRepo func(t *testing.T) (storage.Transactor, user.Repository)
+}{
+	{"SQLite", func(t *testing.T) (storage.Transactor, user.Repository) {
+		db := databasetest.NewSQLite(t)
+		return database.NewSQLiteTransactor(db), user.NewSQLiteUserRepository(db)
+	}},
+	{"Postgres", func(t *testing.T) (storage.Transactor, user.Repository) {
+		pool := databasetest.NewPool(t)
+		return database.NewPostgresTransactor(pool), user.NewPostgresUserRepository(pool)
+	}},
+}
+
+func TestConcurrentProfileUpdatesKeepEveryChange(t *testing.T) {
+	const updates = 10
+
+	for _, backend := range profileBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			tx, repo := backend.newRepo(t)
+			service := user.NewProfileService(tx, repo, user.MetadataRules{MaxBytes: 1024})
+			ctx := context.Background()
+
+			userID, err := repo.CreateUser(ctx, uuid.NewString()+"@example.com", "hash")
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+
+			for i := range updates {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					<-start
+					patch := json.RawMessage(fmt.Sprintf(`{"key%d":true}`, i))
+					if _, err := service.UpdateProfile(ctx, userID, user.ProfileUpdate{UserMetadata: patch}); err != nil {
+						t.Errorf("UpdateProfile: %v", err)
+					}
+				}()
+			}
+
+			close(start)
+			wg.Wait()
+
+			u, err := repo.GetUserByID(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+
+			var metadata map[string]any
+			if err := json.Unmarshal(u.UserMetadata, &metadata); err != nil {
+				t.Fatalf("decoding user_metadata: %v", err)
+			}
+			if len(metadata) != updates {
+				t.Errorf("user_metadata has %d keys after %d concurrent updates, want %d: %s", len(metadata), updates, updates, u.UserMetadata)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileLeavesMissingFieldsAlone(t *testing.T) {
+	repo := user.NewMemoryUserRepository()
+	service := user.NewProfileService(storage.NopTransactor{}, repo, user.MetadataRules{MaxBytes: 1024})
+	ctx := context.Background()
+
+	userID, err := repo.CreateUser(ctx, uuid.NewString()+"@example.com", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	name, locale := " Ada ", "en-us"
+	if _, err := service.UpdateProfile(ctx, userID, user.ProfileUpdate{DisplayName: &name, Locale: &locale}); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+
+	empty := ""
+	updated, err := service.UpdateProfile(ctx, userID, user.ProfileUpdate{Locale: &empty})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+
+	if updated.DisplayName == nil || *updated.DisplayName != "Ada" {
+		t.Errorf("DisplayName = %v, want Ada", updated.DisplayName)
+	}
+	if updated.Locale != nil {
+		t.Errorf("Locale = %q, want it cleared", *updated.Locale)
+	}
+}
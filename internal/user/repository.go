@@ -2,13 +2,52 @@ package user
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrEmailInUse is returned by CreateUser and UpdateEmail when another user
+// already has the email.
+var ErrEmailInUse = errors.New("email already in use")
+
 type Repository interface {
 	CreateUser(ctx context.Context, email, passwordHash string) (uuid.UUID, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
+	// GetUserByIDForUpdate also locks the user until the surrounding
+	// transaction ends, so a read-modify-write can't lose a concurrent update
+	GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	// GetUserByPreviousUsername finds the user who last gave up the username
+	GetUserByPreviousUsername(ctx context.Context, username string) (*User, error)
+	// ListPreviousUsernames returns the names the user holds in the history,
+	// most recently given up first
+	ListPreviousUsernames(ctx context.Context, id uuid.UUID) ([]PreviousUsername, error)
+	ListUsers(ctx context.Context, limit, offset int) ([]User, error)
+	// ListUserEmails pages through users in creation order like ListUsers,
+	// reading only the columns of the first migration
+	ListUserEmails(ctx context.Context, limit, offset int) ([]UserEmail, error)
 	UpdateLastLogin(ctx context.Context, id uuid.UUID) error
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, profile Profile) error
+	UpdateAppMetadata(ctx context.Context, id uuid.UUID, metadata json.RawMessage) error
+	// UpdateUsername sets the username and moves the previous one to the
+	// history, where it stays held by the user. It returns ErrUsernameTaken if
+	// another user has the name now or had it before.
+	UpdateUsername(ctx context.Context, id uuid.UUID, username string) error
+	LockUser(ctx context.Context, id uuid.UUID) error
+	UnlockUser(ctx context.Context, id uuid.UUID) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	// SetVerifiedPhoneNumber replaces the phone number with one the user just
+	// proved they own. It returns storage.ErrNotFound for unknown users.
+	SetVerifiedPhoneNumber(ctx context.Context, id uuid.UUID, phoneNumber string) error
+	ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error
+	CancelDeletion(ctx context.Context, id uuid.UUID) error
+	// PurgeDeletedUsers deletes up to batchSize users whose deletion was due
+	// before the time, with everything they own
+	PurgeDeletedUsers(ctx context.Context, before time.Time, batchSize int) (int64, error)
 }
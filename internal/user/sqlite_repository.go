@@ -0,0 +1,524 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/database"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+)
+
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db}
+}
+
+func (r *SQLiteUserRepository) conn(ctx context.Context) database.SQLiteQuerier {
+	return database.SQLiteConn(ctx, r.db)
+}
+
+func (r *SQLiteUserRepository) CreateUser(ctx context.Context, email, passwordHash string) (uuid.UUID, error) {
+	id := uuid.New()
+
+	q := `
+		INSERT INTO users (id, created_at, email, email_key, password_hash)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, id, time.Now().UTC(), NormalizeEmail(email), EmailKey(email), passwordHash)
+	if database.IsUniqueViolation(err) {
+		return uuid.Nil, ErrEmailInUse
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (r *SQLiteUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE email_key = ?
+	`
+
+	return scanSQLiteUser(r.conn(ctx).QueryRowContext(ctx, q, EmailKey(email)))
+}
+
+func (r *SQLiteUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE id = ?
+	`
+
+	return scanSQLiteUser(r.conn(ctx).QueryRowContext(ctx, q, id))
+}
+
+// GetUserByIDForUpdate needs no row lock: SQLite has no FOR UPDATE, but every
+// transaction takes the database write lock when it begins (_txlock=immediate)
+func (r *SQLiteUserRepository) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (*User, error) {
+	return r.GetUserByID(ctx, id)
+}
+
+func (r *SQLiteUserRepository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		WHERE username_key = ?
+	`
+
+	return scanSQLiteUser(r.conn(ctx).QueryRowContext(ctx, q, UsernameKey(username)))
+}
+
+func (r *SQLiteUserRepository) GetUserByPreviousUsername(ctx context.Context, username string) (*User, error) {
+	q := `
+		SELECT u.id, u.created_at, u.email, u.username, u.username_changed_at, u.display_name, u.avatar_url, u.locale, u.timezone, u.user_metadata, u.app_metadata, u.password_hash, u.last_login, u.phone_number, u.phone_verified, u.deletion_scheduled_at, u.locked_at
+		FROM username_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.username_key = ?
+	`
+
+	return scanSQLiteUser(r.conn(ctx).QueryRowContext(ctx, q, UsernameKey(username)))
+}
+
+func (r *SQLiteUserRepository) ListPreviousUsernames(ctx context.Context, id uuid.UUID) ([]PreviousUsername, error) {
+	q := `
+		SELECT username, created_at
+		FROM username_history
+		WHERE user_id = ?
+		ORDER BY created_at DESC, username_key
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []PreviousUsername
+
+	for rows.Next() {
+		var username PreviousUsername
+
+		if err := rows.Scan(&username.Username, &username.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	return usernames, rows.Err()
+}
+
+func (r *SQLiteUserRepository) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	q := `
+		SELECT id, created_at, email, username, username_changed_at, display_name, avatar_url, locale, timezone, user_metadata, app_metadata, password_hash, last_login, phone_number, phone_verified, deletion_scheduled_at, locked_at
+		FROM users
+		ORDER BY created_at
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *SQLiteUserRepository) ListUserEmails(ctx context.Context, limit, offset int) ([]UserEmail, error) {
+	q := `
+		SELECT id, email, created_at, last_login
+		FROM users
+		ORDER BY created_at
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []UserEmail
+
+	for rows.Next() {
+		var email UserEmail
+		var lastLogin sql.NullTime
+
+		if err := rows.Scan(&email.ID, &email.Email, &email.CreatedAt, &lastLogin); err != nil {
+			return nil, err
+		}
+
+		if lastLogin.Valid {
+			email.LastLogin = &lastLogin.Time
+		}
+
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+func scanSQLiteUser(row interface{ Scan(dest ...any) error }) (*User, error) {
+	var user User
+	var username sql.NullString
+	var displayName, avatarURL, locale, timezone sql.NullString
+	var userMetadata, appMetadata []byte
+	var usernameChangedAt sql.NullTime
+	var lastLogin sql.NullTime
+	var phoneNumber sql.NullString
+	var deletionScheduledAt sql.NullTime
+	var lockedAt sql.NullTime
+
+	err := row.Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Email,
+		&username,
+		&usernameChangedAt,
+		&displayName,
+		&avatarURL,
+		&locale,
+		&timezone,
+		&userMetadata,
+		&appMetadata,
+		&user.PasswordHash,
+		&lastLogin,
+		&phoneNumber,
+		&user.PhoneVerified,
+		&deletionScheduledAt,
+		&lockedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if username.Valid {
+		user.Username = &username.String
+	}
+
+	if usernameChangedAt.Valid {
+		user.UsernameChangedAt = &usernameChangedAt.Time
+	}
+
+	if displayName.Valid {
+		user.DisplayName = &displayName.String
+	}
+
+	if avatarURL.Valid {
+		user.AvatarURL = &avatarURL.String
+	}
+
+	if locale.Valid {
+		user.Locale = &locale.String
+	}
+
+	if timezone.Valid {
+		user.Timezone = &timezone.String
+	}
+
+	user.UserMetadata = userMetadata
+	user.AppMetadata = appMetadata
+
+	if lastLogin.Valid {
+		user.LastLogin = &lastLogin.Time
+	}
+
+	if phoneNumber.Valid {
+		user.PhoneNumber = &phoneNumber.String
+	}
+
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+
+	if lockedAt.Valid {
+		user.LockedAt = &lockedAt.Time
+	}
+
+	return &user, nil
+}
+
+func (r *SQLiteUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET last_login = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	q := `
+		UPDATE users
+		SET email = ?, email_key = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, NormalizeEmail(email), EmailKey(email), id)
+	if database.IsUniqueViolation(err) {
+		return ErrEmailInUse
+	}
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	q := `
+		UPDATE users
+		SET password_hash = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, passwordHash, id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profile Profile) error {
+	q := `
+		UPDATE users
+		SET display_name = ?, avatar_url = ?, locale = ?, timezone = ?, user_metadata = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, profile.DisplayName, profile.AvatarURL, profile.Locale, profile.Timezone, string(profile.UserMetadata), id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UpdateAppMetadata(ctx context.Context, id uuid.UUID, metadata json.RawMessage) error {
+	q := `
+		UPDATE users
+		SET app_metadata = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, string(metadata), id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UpdateUsername(ctx context.Context, id uuid.UUID, username string) error {
+	key := UsernameKey(username)
+
+	return database.NewSQLiteTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		var previous, previousKey sql.NullString
+
+		err := r.conn(ctx).QueryRowContext(ctx, `SELECT username, username_key FROM users WHERE id = ?`, id).Scan(&previous, &previousKey)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// A name in the history belongs to whoever gave it up, who may take it back
+		var holder uuid.UUID
+
+		err = r.conn(ctx).QueryRowContext(ctx, `SELECT user_id FROM username_history WHERE username_key = ?`, key).Scan(&holder)
+		if err == nil && holder != id {
+			return ErrUsernameTaken
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if _, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM username_history WHERE username_key = ?`, key); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		if previous.Valid && previousKey.String != key {
+			q := `
+				INSERT INTO username_history (username_key, username, user_id, created_at)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (username_key) DO UPDATE SET username = excluded.username, created_at = excluded.created_at
+			`
+
+			if _, err := r.conn(ctx).ExecContext(ctx, q, previousKey.String, previous.String, id, now); err != nil {
+				return err
+			}
+		}
+
+		q := `
+			UPDATE users
+			SET username = ?, username_key = ?, username_changed_at = ?
+			WHERE id = ?
+		`
+
+		_, err = r.conn(ctx).ExecContext(ctx, q, NormalizeUsername(username), key, now, id)
+		if database.IsUniqueViolation(err) {
+			return ErrUsernameTaken
+		}
+
+		return err
+	})
+}
+
+func (r *SQLiteUserRepository) LockUser(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET locked_at = ?
+		WHERE id = ? AND locked_at IS NULL
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, time.Now().UTC(), id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) UnlockUser(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET locked_at = NULL
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return database.NewSQLiteTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		var email string
+
+		err := r.conn(ctx).QueryRowContext(ctx, `DELETE FROM users WHERE id = ? RETURNING email`, id).Scan(&email)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Magic links reference the email rather than the user, so the foreign key cascade doesn't reach them
+		_, err = r.conn(ctx).ExecContext(ctx, `DELETE FROM magic_links WHERE email = ?`, email)
+
+		return err
+	})
+}
+
+func (r *SQLiteUserRepository) SetVerifiedPhoneNumber(ctx context.Context, id uuid.UUID, phoneNumber string) error {
+	q := `
+		UPDATE users
+		SET phone_number = ?, phone_verified = true
+		WHERE id = ?
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, q, phoneNumber, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	q := `
+		UPDATE users
+		SET deletion_scheduled_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, at.UTC(), id)
+
+	return err
+}
+
+func (r *SQLiteUserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, q, id)
+
+	return err
+}
+
+// PurgeDeletedUsers relies on the foreign key cascade for everything the users
+// own except magic links, which are keyed by email. Both statements pick the
+// same batch since the transaction holds the write lock in between.
+func (r *SQLiteUserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	var purged int64
+
+	before = before.UTC()
+
+	err := database.NewSQLiteTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		batch := `
+			SELECT id
+			FROM users
+			WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?
+			ORDER BY deletion_scheduled_at, id
+			LIMIT ?
+		`
+
+		q := `
+			DELETE FROM magic_links
+			WHERE email IN (SELECT email FROM users WHERE id IN (` + batch + `))
+		`
+
+		if _, err := r.conn(ctx).ExecContext(ctx, q, before, batchSize); err != nil {
+			return err
+		}
+
+		q = `
+			DELETE FROM users
+			WHERE id IN (` + batch + `)
+		`
+
+		result, err := r.conn(ctx).ExecContext(ctx, q, before, batchSize)
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
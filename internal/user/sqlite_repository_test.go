@@ -0,0 +1,17 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/database/databasetest"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user/usertest"
+)
+
+func TestSQLiteUserRepository(t *testing.T) {
+	db := databasetest.NewSQLite(t)
+
+	usertest.RunRepositoryTests(t, func(t *testing.T) user.Repository {
+		return user.NewSQLiteUserRepository(db)
+	})
+}
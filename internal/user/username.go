@@ -0,0 +1,154 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+)
+
+var (
+	// ErrUsernameInvalid is wrapped with the rule the username breaks
+	ErrUsernameInvalid = errors.New("invalid username")
+	// ErrUsernameReserved is returned for names kept for the service itself
+	ErrUsernameReserved = errors.New("username is reserved")
+	// ErrUsernameTaken is returned when another user has the username, now or
+	// before a change, and by UpdateUsername on a unique violation
+	ErrUsernameTaken = errors.New("username is taken")
+)
+
+// Scripts a username's letters may come from. Names mixing scripts are
+// rejected, which rules out most homograph tricks like a Cyrillic "а" in an
+// otherwise Latin name. Han, Hiragana and Katakana count as one since
+// Japanese mixes them.
+var usernameScripts = [][]*unicode.RangeTable{
+	{unicode.Latin},
+	{unicode.Greek},
+	{unicode.Cyrillic},
+	{unicode.Armenian},
+	{unicode.Hebrew},
+	{unicode.Arabic},
+	{unicode.Devanagari},
+	{unicode.Thai},
+	{unicode.Hangul},
+	{unicode.Han, unicode.Hiragana, unicode.Katakana},
+}
+
+// Characters folded together by UsernameKey, after lowercasing, because they
+// are easily mistaken for each other. A small subset of the Unicode
+// confusables data.
+var usernameConfusables = map[rune]rune{
+	'0': 'o',
+	'1': 'l',
+	'|': 'l',
+	'-': '_',
+	'.': '_',
+	// Greek
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c',
+	'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+}
+
+var reservedUsernames = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		"about", "account", "accounts", "admin", "administrator", "api", "app", "auth",
+		"billing", "blog", "contact", "dashboard", "docs", "help", "home", "info",
+		"login", "logout", "mail", "me", "moderator", "null", "official", "owner",
+		"postmaster", "privacy", "register", "root", "security", "settings", "signin",
+		"signup", "staff", "status", "support", "system", "terms", "undefined", "user",
+		"users", "webmaster", "www",
+	} {
+		reservedUsernames[UsernameKey(name)] = true
+	}
+}
+
+// NormalizeUsername returns the form a username is stored and displayed in:
+// trimmed and NFKC-normalized, so full-width and other compatibility forms
+// become their plain equivalents.
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(strings.TrimSpace(username))
+}
+
+// UsernameKey returns the skeleton usernames are unique by: case-folded, with
+// confusable characters and the separators - . _ folded together, so "J0hn.Doe"
+// and "john_doe" can't belong to different users.
+func UsernameKey(username string) string {
+	var b strings.Builder
+
+	for _, r := range NormalizeUsername(username) {
+		r = unicode.ToLower(r)
+
+		if mapped, ok := usernameConfusables[r]; ok {
+			r = mapped
+		}
+
+		b.WriteRune(r)
+	}
+
+	return strings.ReplaceAll(b.String(), "rn", "m")
+}
+
+// ValidateUsername checks a username against the naming rules and the reserved
+// names, returning an error wrapping ErrUsernameInvalid or ErrUsernameReserved.
+func ValidateUsername(username string) error {
+	username = NormalizeUsername(username)
+
+	if n := utf8.RuneCountInString(username); n < minUsernameLength || n > maxUsernameLength {
+		return fmt.Errorf("%w: must be %d to %d characters", ErrUsernameInvalid, minUsernameLength, maxUsernameLength)
+	}
+
+	script := -1
+
+	for i, r := range username {
+		switch {
+		case r == '_' || r == '-' || r == '.':
+			if i == 0 || i == len(username)-1 {
+				return fmt.Errorf("%w: must start and end with a letter or digit", ErrUsernameInvalid)
+			}
+		case r >= '0' && r <= '9':
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks take the script of the letter before them
+			if script < 0 {
+				return fmt.Errorf("%w: accents must follow a letter", ErrUsernameInvalid)
+			}
+		case unicode.IsLetter(r):
+			s := scriptOf(r)
+			if s < 0 {
+				return fmt.Errorf("%w: contains letters from an unsupported script", ErrUsernameInvalid)
+			}
+			if script >= 0 && s != script {
+				return fmt.Errorf("%w: mixes letters from different scripts", ErrUsernameInvalid)
+			}
+			script = s
+		default:
+			return fmt.Errorf("%w: may only contain letters, digits, _ - and .", ErrUsernameInvalid)
+		}
+	}
+
+	if reservedUsernames[UsernameKey(username)] {
+		return ErrUsernameReserved
+	}
+
+	return nil
+}
+
+func scriptOf(r rune) int {
+	for i, tables := range usernameScripts {
+		if unicode.In(r, tables...) {
+			return i
+		}
+	}
+
+	return -1
+}
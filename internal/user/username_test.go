@@ -0,0 +1,57 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+func TestUsernameKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"JohnDoe", "johndoe"},
+		{"j0hn.doe", "john_doe"},
+		{"bill-1", "BiLL_l"},
+		{"modern", "rnodern"},
+		// Cyrillic а and о in an otherwise Latin-looking name
+		{"bоbа", "boba"},
+		// Full-width letters
+		{"ａlice", "alice"},
+	}
+
+	for _, tt := range tests {
+		if a, b := user.UsernameKey(tt.a), user.UsernameKey(tt.b); a != b {
+			t.Errorf("UsernameKey(%q) = %q, UsernameKey(%q) = %q, want equal", tt.a, a, tt.b, b)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     error
+	}{
+		{"john_doe", nil},
+		{"José.99", nil},
+		{"иван", nil},
+		{"山田たろう", nil},
+		{"ab", user.ErrUsernameInvalid},
+		{"abcdefghijklmnopqrstuvwxyz01234", user.ErrUsernameInvalid},
+		{"_john", user.ErrUsernameInvalid},
+		{"john.", user.ErrUsernameInvalid},
+		{"john doe", user.ErrUsernameInvalid},
+		{"john@doe", user.ErrUsernameInvalid},
+		{"pаypal", user.ErrUsernameInvalid},
+		{"́abc", user.ErrUsernameInvalid},
+		{"Admin", user.ErrUsernameReserved},
+		{"r00t", user.ErrUsernameReserved},
+	}
+
+	for _, tt := range tests {
+		if err := user.ValidateUsername(tt.username); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateUsername(%q) = %v, want %v", tt.username, err, tt.want)
+		}
+	}
+}
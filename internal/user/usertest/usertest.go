@@ -0,0 +1,605 @@
+// Package usertest holds the conformance suite every user.Repository
+// implementation must pass.
+package usertest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+)
+
+// RunRepositoryTests exercises repo through the user.Repository interface.
+// The backing store may be shared with other tests, so every case works on
+// users it created itself.
+func RunRepositoryTests(t *testing.T, newRepo func(t *testing.T) user.Repository) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := uniqueEmail()
+
+		id, err := repo.CreateUser(ctx, email, "hash")
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if id == uuid.Nil {
+			t.Fatal("CreateUser returned a nil ID")
+		}
+
+		byID, err := repo.GetUserByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if byID.Email != email || byID.PasswordHash != "hash" {
+			t.Errorf("GetUserByID = %q/%q, want %q/%q", byID.Email, byID.PasswordHash, email, "hash")
+		}
+		if byID.CreatedAt.IsZero() {
+			t.Error("CreatedAt is not set")
+		}
+		if byID.LastLogin != nil || byID.PhoneNumber != nil || byID.LockedAt != nil || byID.DeletionScheduledAt != nil {
+			t.Error("optional fields of a new user are not nil")
+		}
+
+		forUpdate, err := repo.GetUserByIDForUpdate(ctx, id)
+		if err != nil {
+			t.Fatalf("GetUserByIDForUpdate: %v", err)
+		}
+		if forUpdate.Email != email {
+			t.Errorf("GetUserByIDForUpdate email = %q, want %q", forUpdate.Email, email)
+		}
+
+		byEmail, err := repo.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetUserByEmail: %v", err)
+		}
+		if byEmail.ID != id {
+			t.Errorf("GetUserByEmail ID = %s, want %s", byEmail.ID, id)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		if _, err := repo.GetUserByID(ctx, uuid.New()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByID error = %v, want storage.ErrNotFound", err)
+		}
+		if _, err := repo.GetUserByIDForUpdate(ctx, uuid.New()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByIDForUpdate error = %v, want storage.ErrNotFound", err)
+		}
+		if _, err := repo.GetUserByEmail(ctx, uniqueEmail()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByEmail error = %v, want storage.ErrNotFound", err)
+		}
+		if err := repo.DeleteUser(ctx, uuid.New()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("DeleteUser error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("UniqueEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := uniqueEmail()
+
+		if _, err := repo.CreateUser(ctx, email, "hash"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := repo.CreateUser(ctx, email, "hash"); !errors.Is(err, user.ErrEmailInUse) {
+			t.Errorf("CreateUser with a duplicate email error = %v, want user.ErrEmailInUse", err)
+		}
+
+		other := mustCreate(t, repo)
+		if err := repo.UpdateEmail(ctx, other, email); !errors.Is(err, user.ErrEmailInUse) {
+			t.Errorf("UpdateEmail to a taken email error = %v, want user.ErrEmailInUse", err)
+		}
+	})
+
+	t.Run("CaseInsensitiveEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		local := "Case." + uuid.NewString()
+
+		id, err := repo.CreateUser(ctx, "  "+local+"@Example.COM ", "hash")
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		got, err := repo.GetUserByEmail(ctx, strings.ToUpper(local)+"@example.com")
+		if err != nil {
+			t.Fatalf("GetUserByEmail with different case: %v", err)
+		}
+		if got.ID != id {
+			t.Errorf("GetUserByEmail returned user %v, want %v", got.ID, id)
+		}
+		// The display form keeps the local part's case
+		if want := local + "@example.com"; got.Email != want {
+			t.Errorf("Email = %q, want %q", got.Email, want)
+		}
+
+		if _, err := repo.CreateUser(ctx, strings.ToLower(local)+"@example.com", "hash"); !errors.Is(err, user.ErrEmailInUse) {
+			t.Errorf("CreateUser with a differently cased email error = %v, want user.ErrEmailInUse", err)
+		}
+
+		// Changing only the case of one's own email is allowed
+		if err := repo.UpdateEmail(ctx, id, strings.ToLower(local)+"@example.com"); err != nil {
+			t.Fatalf("UpdateEmail: %v", err)
+		}
+	})
+
+	t.Run("ConcurrentCreateWithSameEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := uniqueEmail()
+
+		const attempts = 10
+
+		var wg sync.WaitGroup
+		errs := make(chan error, attempts)
+
+		for range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := repo.CreateUser(ctx, email, "hash")
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, user.ErrEmailInUse):
+				t.Errorf("CreateUser error = %v, want user.ErrEmailInUse", err)
+			}
+		}
+		if created != 1 {
+			t.Errorf("%d concurrent CreateUser calls succeeded, want 1", created)
+		}
+	})
+
+	t.Run("Updates", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+		email := uniqueEmail()
+
+		if err := repo.UpdateLastLogin(ctx, id); err != nil {
+			t.Fatalf("UpdateLastLogin: %v", err)
+		}
+		if err := repo.UpdateEmail(ctx, id, email); err != nil {
+			t.Fatalf("UpdateEmail: %v", err)
+		}
+		if err := repo.UpdatePassword(ctx, id, "new-hash"); err != nil {
+			t.Fatalf("UpdatePassword: %v", err)
+		}
+
+		got := mustGet(t, repo, id)
+		if got.LastLogin == nil {
+			t.Error("LastLogin is not set")
+		}
+		if got.Email != email {
+			t.Errorf("Email = %q, want %q", got.Email, email)
+		}
+		if got.PasswordHash != "new-hash" {
+			t.Errorf("PasswordHash = %q, want %q", got.PasswordHash, "new-hash")
+		}
+	})
+
+	t.Run("LockAndUnlock", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+
+		if err := repo.LockUser(ctx, id); err != nil {
+			t.Fatalf("LockUser: %v", err)
+		}
+
+		lockedAt := mustGet(t, repo, id).LockedAt
+		if lockedAt == nil {
+			t.Fatal("LockedAt is not set")
+		}
+
+		// Locking again keeps the original timestamp
+		if err := repo.LockUser(ctx, id); err != nil {
+			t.Fatalf("LockUser: %v", err)
+		}
+		if again := mustGet(t, repo, id).LockedAt; again == nil || !again.Equal(*lockedAt) {
+			t.Errorf("LockedAt changed from %v to %v", lockedAt, again)
+		}
+
+		if err := repo.UnlockUser(ctx, id); err != nil {
+			t.Fatalf("UnlockUser: %v", err)
+		}
+		if mustGet(t, repo, id).LockedAt != nil {
+			t.Error("LockedAt is still set after UnlockUser")
+		}
+	})
+
+	t.Run("PhoneNumber", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+
+		if got := mustGet(t, repo, id); got.PhoneNumber != nil || got.PhoneVerified {
+			t.Errorf("new user phone = %v/%t, want none", got.PhoneNumber, got.PhoneVerified)
+		}
+
+		for _, number := range []string{"+15550100", "+15550199"} {
+			if err := repo.SetVerifiedPhoneNumber(ctx, id, number); err != nil {
+				t.Fatalf("SetVerifiedPhoneNumber: %v", err)
+			}
+
+			got := mustGet(t, repo, id)
+			if got.PhoneNumber == nil || *got.PhoneNumber != number || !got.PhoneVerified {
+				t.Errorf("phone = %v/%t, want %s/true", got.PhoneNumber, got.PhoneVerified, number)
+			}
+		}
+
+		if err := repo.SetVerifiedPhoneNumber(ctx, uuid.New(), "+15550100"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("SetVerifiedPhoneNumber for an unknown user error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListUsers", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		ids := []uuid.UUID{mustCreate(t, repo), mustCreate(t, repo), mustCreate(t, repo)}
+
+		var listed []uuid.UUID
+
+		for offset := 0; ; offset += 2 {
+			page, err := repo.ListUsers(ctx, 2, offset)
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if len(page) > 2 {
+				t.Fatalf("ListUsers returned %d users, want at most 2", len(page))
+			}
+			if len(page) == 0 {
+				break
+			}
+
+			for _, u := range page {
+				listed = append(listed, u.ID)
+			}
+		}
+
+		// Other users may exist, but ours must appear once each in creation order
+		next := 0
+		for _, id := range listed {
+			if next < len(ids) && id == ids[next] {
+				next++
+			}
+		}
+		if next != len(ids) {
+			t.Errorf("ListUsers did not return the created users in creation order")
+		}
+	})
+
+	t.Run("ListUserEmails", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		ids := []uuid.UUID{mustCreate(t, repo), mustCreate(t, repo), mustCreate(t, repo)}
+		if err := repo.UpdateLastLogin(ctx, ids[1]); err != nil {
+			t.Fatalf("UpdateLastLogin: %v", err)
+		}
+
+		listed := make(map[uuid.UUID]user.UserEmail)
+		var order []uuid.UUID
+
+		for offset := 0; ; offset += 2 {
+			page, err := repo.ListUserEmails(ctx, 2, offset)
+			if err != nil {
+				t.Fatalf("ListUserEmails: %v", err)
+			}
+			if len(page) > 2 {
+				t.Fatalf("ListUserEmails returned %d users, want at most 2", len(page))
+			}
+			if len(page) == 0 {
+				break
+			}
+
+			for _, e := range page {
+				listed[e.ID] = e
+				order = append(order, e.ID)
+			}
+		}
+
+		next := 0
+		for _, id := range order {
+			if next < len(ids) && id == ids[next] {
+				next++
+			}
+		}
+		if next != len(ids) {
+			t.Fatalf("ListUserEmails did not return the created users in creation order")
+		}
+
+		for i, id := range ids {
+			want := mustGet(t, repo, id)
+			got := listed[id]
+			if got.Email != want.Email || got.CreatedAt.IsZero() {
+				t.Errorf("ListUserEmails user %d = %q/%v, want %q and a creation time", i, got.Email, got.CreatedAt, want.Email)
+			}
+			if (got.LastLogin != nil) != (i == 1) {
+				t.Errorf("ListUserEmails user %d last login = %v, want it set only for user 1", i, got.LastLogin)
+			}
+		}
+	})
+
+	t.Run("DeleteUser", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+
+		if err := repo.DeleteUser(ctx, id); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if _, err := repo.GetUserByID(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByID after delete error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+
+		u := mustGet(t, repo, id)
+		if string(u.UserMetadata) != "{}" || string(u.AppMetadata) != "{}" {
+			t.Errorf("metadata of a new user = %s/%s, want {}/{}", u.UserMetadata, u.AppMetadata)
+		}
+
+		displayName, locale := "Ada", "en-GB"
+		profile := user.Profile{
+			DisplayName:  &displayName,
+			Locale:       &locale,
+			UserMetadata: json.RawMessage(`{"theme":"dark"}`),
+		}
+
+		if err := repo.UpdateProfile(ctx, id, profile); err != nil {
+			t.Fatalf("UpdateProfile: %v", err)
+		}
+		if err := repo.UpdateAppMetadata(ctx, id, json.RawMessage(`{"plan":"pro"}`)); err != nil {
+			t.Fatalf("UpdateAppMetadata: %v", err)
+		}
+
+		u = mustGet(t, repo, id)
+		if u.DisplayName == nil || *u.DisplayName != displayName || u.Locale == nil || *u.Locale != locale {
+			t.Errorf("DisplayName/Locale = %v/%v, want %q/%q", u.DisplayName, u.Locale, displayName, locale)
+		}
+		if u.AvatarURL != nil || u.Timezone != nil {
+			t.Errorf("AvatarURL/Timezone = %v/%v, want nil", u.AvatarURL, u.Timezone)
+		}
+		assertJSON(t, "UserMetadata", u.UserMetadata, `{"theme":"dark"}`)
+		assertJSON(t, "AppMetadata", u.AppMetadata, `{"plan":"pro"}`)
+	})
+
+	t.Run("Username", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+		other := mustCreate(t, repo)
+		name := uniqueUsername()
+
+		if err := repo.UpdateUsername(ctx, id, name); err != nil {
+			t.Fatalf("UpdateUsername: %v", err)
+		}
+
+		u := mustGet(t, repo, id)
+		if u.Username == nil || *u.Username != name || u.UsernameChangedAt == nil {
+			t.Errorf("Username = %v, UsernameChangedAt = %v, want %q and a time", u.Username, u.UsernameChangedAt, name)
+		}
+
+		// Lookups go through the confusable-folded key
+		byName, err := repo.GetUserByUsername(ctx, strings.ToUpper(name))
+		if err != nil {
+			t.Fatalf("GetUserByUsername: %v", err)
+		}
+		if byName.ID != id {
+			t.Errorf("GetUserByUsername ID = %s, want %s", byName.ID, id)
+		}
+
+		if err := repo.UpdateUsername(ctx, other, strings.ToUpper(name)); !errors.Is(err, user.ErrUsernameTaken) {
+			t.Errorf("UpdateUsername with a taken name error = %v, want user.ErrUsernameTaken", err)
+		}
+		if _, err := repo.GetUserByUsername(ctx, uniqueUsername()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByUsername error = %v, want storage.ErrNotFound", err)
+		}
+		if err := repo.UpdateUsername(ctx, uuid.New(), uniqueUsername()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("UpdateUsername for a missing user error = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("UsernameHistory", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		id := mustCreate(t, repo)
+		other := mustCreate(t, repo)
+		first, second := uniqueUsername(), uniqueUsername()
+
+		for _, name := range []string{first, second} {
+			if err := repo.UpdateUsername(ctx, id, name); err != nil {
+				t.Fatalf("UpdateUsername: %v", err)
+			}
+		}
+
+		if _, err := repo.GetUserByUsername(ctx, first); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByUsername for the old name error = %v, want storage.ErrNotFound", err)
+		}
+
+		history, err := repo.ListPreviousUsernames(ctx, id)
+		if err != nil {
+			t.Fatalf("ListPreviousUsernames: %v", err)
+		}
+		if len(history) != 1 || history[0].Username != first || history[0].ChangedAt.IsZero() {
+			t.Errorf("ListPreviousUsernames = %+v, want only %q", history, first)
+		}
+
+		previous, err := repo.GetUserByPreviousUsername(ctx, first)
+		if err != nil {
+			t.Fatalf("GetUserByPreviousUsername: %v", err)
+		}
+		if previous.ID != id {
+			t.Errorf("GetUserByPreviousUsername ID = %s, want %s", previous.ID, id)
+		}
+
+		// The old name stays held by its previous owner
+		if err := repo.UpdateUsername(ctx, other, first); !errors.Is(err, user.ErrUsernameTaken) {
+			t.Errorf("UpdateUsername with a previous name error = %v, want user.ErrUsernameTaken", err)
+		}
+
+		if err := repo.UpdateUsername(ctx, id, first); err != nil {
+			t.Fatalf("UpdateUsername back to the old name: %v", err)
+		}
+		if _, err := repo.GetUserByPreviousUsername(ctx, first); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetUserByPreviousUsername for the reclaimed name error = %v, want storage.ErrNotFound", err)
+		}
+		if previous, err := repo.GetUserByPreviousUsername(ctx, second); err != nil || previous.ID != id {
+			t.Errorf("GetUserByPreviousUsername(%q) = %v, %v, want user %s", second, previous, err, id)
+		}
+		if history, err := repo.ListPreviousUsernames(ctx, id); err != nil || len(history) != 1 || history[0].Username != second {
+			t.Errorf("ListPreviousUsernames after reclaiming = %+v, %v, want only %q", history, err, second)
+		}
+		if history, err := repo.ListPreviousUsernames(ctx, other); err != nil || len(history) != 0 {
+			t.Errorf("ListPreviousUsernames for a user who never renamed = %+v, %v, want none", history, err)
+		}
+
+		if err := repo.DeleteUser(ctx, id); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if err := repo.UpdateUsername(ctx, other, second); err != nil {
+			t.Errorf("UpdateUsername with a deleted user's previous name: %v", err)
+		}
+	})
+
+	t.Run("ScheduledDeletion", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+
+		due := mustCreate(t, repo)
+		alsoDue := mustCreate(t, repo)
+		cancelled := mustCreate(t, repo)
+		later := mustCreate(t, repo)
+
+		past := time.Now().Add(-time.Hour)
+
+		for _, id := range []uuid.UUID{due, alsoDue, cancelled} {
+			if err := repo.ScheduleDeletion(ctx, id, past); err != nil {
+				t.Fatalf("ScheduleDeletion: %v", err)
+			}
+		}
+		if err := repo.ScheduleDeletion(ctx, later, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("ScheduleDeletion: %v", err)
+		}
+		if err := repo.CancelDeletion(ctx, cancelled); err != nil {
+			t.Fatalf("CancelDeletion: %v", err)
+		}
+
+		if mustGet(t, repo, cancelled).DeletionScheduledAt != nil {
+			t.Error("DeletionScheduledAt is still set after CancelDeletion")
+		}
+
+		purged, err := repo.PurgeDeletedUsers(ctx, time.Now(), 1)
+		if err != nil {
+			t.Fatalf("PurgeDeletedUsers: %v", err)
+		}
+		if purged != 1 {
+			t.Errorf("PurgeDeletedUsers with a batch size of 1 purged %d users, want 1", purged)
+		}
+
+		// The store may be shared, so keep purging until the due users are gone
+		for purged > 0 {
+			purged, err = repo.PurgeDeletedUsers(ctx, time.Now(), 100)
+			if err != nil {
+				t.Fatalf("PurgeDeletedUsers: %v", err)
+			}
+		}
+
+		for _, id := range []uuid.UUID{due, alsoDue} {
+			if _, err := repo.GetUserByID(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("due user error = %v, want storage.ErrNotFound", err)
+			}
+		}
+		mustGet(t, repo, cancelled)
+		mustGet(t, repo, later)
+	})
+}
+
+// NewUserID returns a function that creates a user in repo and returns its
+// ID, for suites whose rows reference a user
+func NewUserID(repo user.Repository) func(t *testing.T) uuid.UUID {
+	return func(t *testing.T) uuid.UUID {
+		return mustCreate(t, repo)
+	}
+}
+
+func uniqueEmail() string {
+	return uuid.NewString() + "@example.com"
+}
+
+// assertJSON compares JSON semantically since Postgres JSONB doesn't keep
+// the original formatting
+func assertJSON(t *testing.T, name string, got json.RawMessage, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", name, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
+
+// uniqueUsername returns a valid username no other test uses. Hex digits are
+// avoided since 0 and 1 fold into o and l in the username key.
+func uniqueUsername() string {
+	return "user_" + strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return 'g' + r - '0'
+		}
+		return r
+	}, strings.ReplaceAll(uuid.NewString(), "-", "")[:20])
+}
+
+func mustCreate(t *testing.T, repo user.Repository) uuid.UUID {
+	t.Helper()
+
+	id, err := repo.CreateUser(context.Background(), uniqueEmail(), "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	return id
+}
+
+func mustGet(t *testing.T, repo user.Repository, id uuid.UUID) *user.User {
+	t.Helper()
+
+	u, err := repo.GetUserByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	return u
+}
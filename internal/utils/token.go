@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 )
@@ -11,3 +12,32 @@ func HashToken(token string) string {
 
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+func GenerateRandomToken(numBytes int) (string, error) {
+	b := make([]byte, numBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func GenerateNumericCode(digits int) (string, error) {
+	b := make([]byte, digits)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, digits)
+	for i := range b {
+		// 256 is not a multiple of 10, so reject the top of the range to keep digits uniform
+		for b[i] >= 250 {
+			if _, err := rand.Read(b[i : i+1]); err != nil {
+				return "", err
+			}
+		}
+		code[i] = '0' + b[i]%10
+	}
+
+	return string(code), nil
+}
@@ -1,5 +1,26 @@
 package utils
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"strings"
 
-var Validate = validator.New(validator.WithRequiredStructEnabled())
+	"github.com/go-playground/validator/v10"
+)
+
+var Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names so validation errors match the request body
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	return v
+}
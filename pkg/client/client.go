@@ -0,0 +1,351 @@
+// Package client is a typed Go client for the auth API. Sessions are kept in
+// a cookie jar and expired access tokens are refreshed transparently.
+//
+// Admin operations such as locking or deleting users are not covered since the
+// API doesn't expose them; they are only available through the CLI.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	registerPath  = "/api/auth/register"
+	loginPath     = "/api/auth/login"
+	refreshPath   = "/api/auth/refresh"
+	logoutPath    = "/api/auth/logout"
+	mfaVerifyPath = "/api/auth/mfa/verify"
+	profilePath   = "/api/user/profile"
+	sessionsPath  = "/api/user/sessions"
+)
+
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	bearerToken string
+
+	refreshGroup singleflight.Group
+	// Incremented after every successful refresh, so requests that failed with
+	// tokens older than the latest refresh retry without refreshing again
+	refreshGeneration atomic.Uint64
+}
+
+type Option func(*Client)
+
+// WithHTTPClient uses httpClient for every request. A cookie jar is added to a
+// copy of it when it has none.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBearerToken authenticates every request with a static token, such as a
+// personal access token, sent in the Authorization header instead of session
+// cookies. Such tokens are never refreshed.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.bearerToken = token
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.httpClient.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		httpClient := *c.httpClient
+		httpClient.Jar = jar
+		c.httpClient = &httpClient
+	}
+
+	return c, nil
+}
+
+// credentials holds either an email or a username, never both
+type credentials struct {
+	Email    string `json:"email,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+func (c *Client) Register(ctx context.Context, email, password string) (uuid.UUID, error) {
+	var resp struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	if err := c.do(ctx, http.MethodPost, registerPath, credentials{Email: email, Password: password}, &resp); err != nil {
+		return uuid.Nil, err
+	}
+
+	return resp.UserID, nil
+}
+
+// LoginResult reports whether a second factor is still required. When it is,
+// the session is only established after VerifyMFA succeeds.
+type LoginResult struct {
+	MFARequired bool      `json:"mfa_required"`
+	Channel     string    `json:"channel,omitempty"`
+	ChallengeID uuid.UUID `json:"challenge_id,omitempty"`
+}
+
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResult, error) {
+	return c.login(ctx, credentials{Email: email, Password: password})
+}
+
+// LoginWithUsername signs in like Login, identifying the user by username
+func (c *Client) LoginWithUsername(ctx context.Context, username, password string) (*LoginResult, error) {
+	return c.login(ctx, credentials{Username: username, Password: password})
+}
+
+func (c *Client) login(ctx context.Context, creds credentials) (*LoginResult, error) {
+	var result LoginResult
+
+	if err := c.do(ctx, http.MethodPost, loginPath, creds, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) VerifyMFA(ctx context.Context, challengeID uuid.UUID, code string) error {
+	req := struct {
+		ChallengeID uuid.UUID `json:"challenge_id"`
+		Code        string    `json:"code"`
+	}{challengeID, code}
+
+	return c.do(ctx, http.MethodPost, mfaVerifyPath, req, nil)
+}
+
+// Refresh rotates the session explicitly. It is rarely needed since requests
+// refresh automatically when the access token has expired.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, c.refreshGeneration.Load())
+}
+
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, logoutPath, nil, nil)
+}
+
+type Profile struct {
+	ID                  uuid.UUID       `json:"id"`
+	CreatedAt           time.Time       `json:"created_at"`
+	Email               string          `json:"email"`
+	Username            *string         `json:"username,omitempty"`
+	DisplayName         *string         `json:"display_name,omitempty"`
+	AvatarURL           *string         `json:"avatar_url,omitempty"`
+	Locale              *string         `json:"locale,omitempty"`
+	Timezone            *string         `json:"timezone,omitempty"`
+	UserMetadata        json.RawMessage `json:"user_metadata"`
+	AppMetadata         json.RawMessage `json:"app_metadata"`
+	LastLogin           *time.Time      `json:"last_login,omitempty"`
+	PhoneNumber         *string         `json:"phone_number,omitempty"`
+	PhoneVerified       bool            `json:"phone_verified"`
+	DeletionScheduledAt *time.Time      `json:"deletion_scheduled_at,omitempty"`
+}
+
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	var profile Profile
+
+	if err := c.do(ctx, http.MethodGet, profilePath, nil, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// ProfileUpdate only changes the fields that are set. An empty string clears a
+// field and UserMetadata is merged into the stored metadata as a JSON merge
+// patch.
+type ProfileUpdate struct {
+	DisplayName  *string         `json:"display_name,omitempty"`
+	AvatarURL    *string         `json:"avatar_url,omitempty"`
+	Locale       *string         `json:"locale,omitempty"`
+	Timezone     *string         `json:"timezone,omitempty"`
+	UserMetadata json.RawMessage `json:"user_metadata,omitempty"`
+}
+
+func (c *Client) UpdateProfile(ctx context.Context, update ProfileUpdate) (*Profile, error) {
+	var profile Profile
+
+	if err := c.do(ctx, http.MethodPatch, profilePath, update, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// Session is a signed-in device. Its ID changes whenever it is refreshed.
+type Session struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Current marks this client's own session
+	Current bool `json:"current"`
+}
+
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var resp struct {
+		Sessions []Session `json:"sessions"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, sessionsPath, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Sessions, nil
+}
+
+// RevokeSession signs out another session, returning an *Error with status 404
+// when the user has no such active session.
+func (c *Client) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, sessionsPath+"/"+id.String(), nil, nil)
+}
+
+// RevokeOtherSessions signs out every session except this client's own
+func (c *Client) RevokeOtherSessions(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, sessionsPath, nil, nil)
+}
+
+// do sends the request and, if it was rejected for an expired or missing
+// access token, refreshes the session once and retries.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	generation := c.refreshGeneration.Load()
+
+	err := c.send(ctx, method, path, in, out)
+	if !c.shouldRefresh(path, err) {
+		return err
+	}
+
+	if refreshErr := c.refresh(ctx, generation); refreshErr != nil {
+		return err
+	}
+
+	return c.send(ctx, method, path, in, out)
+}
+
+func (c *Client) shouldRefresh(path string, err error) bool {
+	if c.bearerToken != "" {
+		return false
+	}
+
+	switch path {
+	case registerPath, loginPath, refreshPath, mfaVerifyPath:
+		return false
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		return false
+	}
+
+	return errors.Is(err, ErrExpiredToken) || errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrUnauthorized)
+}
+
+// refresh collapses concurrent refreshes into a single request. Rotating the
+// refresh token twice in parallel would look like token reuse to the server
+// and revoke the whole session.
+func (c *Client) refresh(ctx context.Context, generation uint64) error {
+	_, err, _ := c.refreshGroup.Do("refresh", func() (any, error) {
+		if c.refreshGeneration.Load() != generation {
+			return nil, nil
+		}
+
+		// Detached so one caller giving up doesn't fail the refresh for everyone waiting on it
+		if err := c.send(context.WithoutCancel(ctx), http.MethodPost, refreshPath, nil, nil); err != nil {
+			return nil, err
+		}
+
+		c.refreshGeneration.Add(1)
+
+		return nil, nil
+	})
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{}
+
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Code == "" {
+		apiErr = &Error{
+			Code:  strings.ToLower(strings.ReplaceAll(http.StatusText(resp.StatusCode), " ", "_")),
+			Title: http.StatusText(resp.StatusCode),
+		}
+	}
+
+	apiErr.Status = resp.StatusCode
+
+	return apiErr
+}
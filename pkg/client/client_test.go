@@ -0,0 +1,319 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/internal/apierror"
+	"github.com/joacolabadie/go-auth-template-v2/pkg/client"
+)
+
+// fakeAPI imitates the session handling of the auth API: login and refresh
+// set the access_token cookie, and the profile only accepts the latest one.
+type fakeAPI struct {
+	mu          sync.Mutex
+	accessToken string
+	generation  int
+
+	refreshes       atomic.Int32
+	profileRequests atomic.Int32
+	// refreshStatus makes /refresh fail when set
+	refreshStatus int
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *client.Client) {
+	t.Helper()
+
+	api := &fakeAPI{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		api.issue(w)
+		writeJSON(w, http.StatusOK, map[string]any{"mfa_required": false})
+	})
+	mux.HandleFunc("POST /api/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		api.refreshes.Add(1)
+
+		// Slow enough for every concurrent request to fail before it completes
+		time.Sleep(50 * time.Millisecond)
+
+		if api.refreshStatus != 0 {
+			writeProblem(w, api.refreshStatus, "invalid_token")
+			return
+		}
+
+		api.issue(w)
+		writeJSON(w, http.StatusOK, map[string]any{"message": "Token refreshed successfully"})
+	})
+	mux.HandleFunc("GET /api/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		api.profileRequests.Add(1)
+
+		if !api.authorized(r) {
+			writeProblem(w, http.StatusUnauthorized, "expired_token")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"id": uuid.NewString(), "email": "user@example.com"})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := c.Login(context.Background(), "user@example.com", "password"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	return api, c
+}
+
+func (api *fakeAPI) issue(w http.ResponseWriter) {
+	api.mu.Lock()
+	api.generation++
+	api.accessToken = fmt.Sprintf("access-%d", api.generation)
+	token := api.accessToken
+	api.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "access_token", Value: token, Path: "/"})
+	http.SetCookie(w, &http.Cookie{Name: "refresh_token", Value: "refresh-" + token, Path: "/"})
+}
+
+// expire makes the current access token stale, as if its TTL had passed
+func (api *fakeAPI) expire() {
+	api.mu.Lock()
+	api.accessToken = ""
+	api.mu.Unlock()
+}
+
+func (api *fakeAPI) authorized(r *http.Request) bool {
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return false
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return api.accessToken != "" && cookie.Value == api.accessToken
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeProblem(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "code": code, "title": http.StatusText(status)})
+}
+
+func TestRetryAfterRefresh(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.expire()
+
+	profile, err := c.Profile(context.Background())
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if profile.Email != "user@example.com" {
+		t.Errorf("Email = %q, want user@example.com", profile.Email)
+	}
+
+	if got := api.refreshes.Load(); got != 1 {
+		t.Errorf("refreshed %d times, want 1", got)
+	}
+	if got := api.profileRequests.Load(); got != 2 {
+		t.Errorf("profile requested %d times, want 2", got)
+	}
+}
+
+func TestConcurrentUnauthorizedRequestsRefreshOnce(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.expire()
+
+	const requests = 10
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, requests)
+
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			<-start
+			_, errs[i] = c.Profile(context.Background())
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Profile: %v", err)
+		}
+	}
+
+	// Two refreshes would rotate the same refresh token twice, which the
+	// server treats as reuse and answers by revoking the session
+	if got := api.refreshes.Load(); got != 1 {
+		t.Errorf("refreshed %d times for %d concurrent 401s, want 1", got, requests)
+	}
+}
+
+func TestFailedRefreshReturnsOriginalError(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.refreshStatus = http.StatusUnauthorized
+	api.expire()
+
+	_, err := c.Profile(context.Background())
+	if !errors.Is(err, client.ErrExpiredToken) {
+		t.Errorf("Profile error = %v, want client.ErrExpiredToken", err)
+	}
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("Profile error = %#v, want a 401 *client.Error", err)
+	}
+}
+
+func TestBearerTokenIsNotRefreshed(t *testing.T) {
+	var refreshes atomic.Int32
+	var authorization string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+	})
+	mux.HandleFunc("GET /api/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		writeProblem(w, http.StatusUnauthorized, "invalid_token")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL, client.WithBearerToken("gat_token"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := c.Profile(context.Background()); !errors.Is(err, client.ErrInvalidToken) {
+		t.Errorf("Profile error = %v, want client.ErrInvalidToken", err)
+	}
+	if authorization != "Bearer gat_token" {
+		t.Errorf("Authorization = %q, want Bearer gat_token", authorization)
+	}
+	if got := refreshes.Load(); got != 0 {
+		t.Errorf("refreshed %d times with a bearer token, want 0", got)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	current, other := uuid.New(), uuid.New()
+	var revoked []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/user/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"sessions": []map[string]any{
+			{"id": current, "created_at": time.Now(), "expires_at": time.Now().Add(time.Hour), "current": true},
+			{"id": other, "created_at": time.Now(), "expires_at": time.Now().Add(time.Hour), "current": false},
+		}})
+	})
+	mux.HandleFunc("DELETE /api/user/sessions", func(w http.ResponseWriter, r *http.Request) {
+		revoked = append(revoked, "others")
+		writeJSON(w, http.StatusOK, map[string]any{"message": "Other sessions revoked successfully"})
+	})
+	mux.HandleFunc("DELETE /api/user/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != other.String() {
+			writeProblem(w, http.StatusNotFound, "not_found")
+			return
+		}
+		revoked = append(revoked, r.PathValue("id"))
+		writeJSON(w, http.StatusOK, map[string]any{"message": "Session revoked successfully"})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != current || !sessions[0].Current || sessions[1].Current {
+		t.Errorf("ListSessions = %+v, want the current session first", sessions)
+	}
+
+	if err := c.RevokeSession(ctx, other); err != nil {
+		t.Errorf("RevokeSession: %v", err)
+	}
+
+	var apiErr *client.Error
+	if err := c.RevokeSession(ctx, uuid.New()); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("RevokeSession of an unknown session error = %v, want a 404", err)
+	}
+
+	if err := c.RevokeOtherSessions(ctx); err != nil {
+		t.Errorf("RevokeOtherSessions: %v", err)
+	}
+
+	if want := []string{other.String(), "others"}; fmt.Sprint(revoked) != fmt.Sprint(want) {
+		t.Errorf("revoked %v, want %v", revoked, want)
+	}
+}
+
+// Every code the API emits, other than internal errors, must map to a sentinel
+func TestErrorCodesMirrorTheAPI(t *testing.T) {
+	codes := []string{
+		apierror.CodeValidationFailed,
+		apierror.CodeEmailInUse,
+		apierror.CodeInvalidCredentials,
+		apierror.CodeInvalidToken,
+		apierror.CodeExpiredToken,
+		apierror.CodeTooManyRequests,
+		apierror.CodeInvalidCode,
+		apierror.CodeTooManyAttempts,
+		apierror.CodePhoneNotVerified,
+		apierror.CodeAccountLocked,
+		apierror.CodeInvalidUsername,
+		apierror.CodeUsernameReserved,
+		apierror.CodeUsernameTaken,
+		apierror.CodeUsernameCooldown,
+		apierror.CodeInvalidMetadata,
+		apierror.CodeMetadataTooLarge,
+		apierror.CodeInsufficientScope,
+	}
+
+	for _, code := range codes {
+		if errors.Unwrap(&client.Error{Code: code}) == nil {
+			t.Errorf("code %q has no matching client error", code)
+		}
+	}
+
+	if err := (&client.Error{Code: apierror.CodeUsernameTaken}); !errors.Is(err, client.ErrUsernameTaken) {
+		t.Errorf("errors.Is(%v, client.ErrUsernameTaken) = false, want true", err)
+	}
+}
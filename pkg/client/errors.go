@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors mirroring the server's auth errors. Every *Error unwraps to
+// the one matching its code, so callers can use errors.Is.
+var (
+	ErrEmailInUse         = errors.New("email already in use")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrExpiredToken       = errors.New("token has expired")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrInvalidCode        = errors.New("invalid verification code")
+	ErrTooManyAttempts    = errors.New("too many verification attempts")
+	ErrPhoneNotVerified   = errors.New("phone number not verified")
+	ErrAccountLocked      = errors.New("account is locked")
+	ErrInsufficientScope  = errors.New("token lacks the required scope")
+	ErrValidation         = errors.New("request validation failed")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrUsernameInvalid    = errors.New("username is not valid")
+	ErrUsernameReserved   = errors.New("username is reserved")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrUsernameCooldown   = errors.New("username was changed too recently")
+	ErrMetadataInvalid    = errors.New("invalid metadata")
+	ErrMetadataTooLarge   = errors.New("metadata is too large")
+)
+
+// codeErrors mirrors the codes in internal/apierror
+var codeErrors = map[string]error{
+	"email_in_use":             ErrEmailInUse,
+	"invalid_credentials":      ErrInvalidCredentials,
+	"invalid_token":            ErrInvalidToken,
+	"expired_token":            ErrExpiredToken,
+	"too_many_requests":        ErrTooManyRequests,
+	"invalid_code":             ErrInvalidCode,
+	"too_many_attempts":        ErrTooManyAttempts,
+	"phone_not_verified":       ErrPhoneNotVerified,
+	"account_locked":           ErrAccountLocked,
+	"insufficient_scope":       ErrInsufficientScope,
+	"validation_failed":        ErrValidation,
+	"unauthorized":             ErrUnauthorized,
+	"invalid_username":         ErrUsernameInvalid,
+	"username_reserved":        ErrUsernameReserved,
+	"username_taken":           ErrUsernameTaken,
+	"username_change_cooldown": ErrUsernameCooldown,
+	"invalid_metadata":         ErrMetadataInvalid,
+	"metadata_too_large":       ErrMetadataTooLarge,
+}
+
+// Error is a problem details response returned by the API
+type Error struct {
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Detail)
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Title)
+}
+
+func (e *Error) Unwrap() error {
+	return codeErrors[e.Code]
+}
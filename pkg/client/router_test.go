@@ -0,0 +1,103 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joacolabadie/go-auth-template-v2/internal/apierror"
+	"github.com/joacolabadie/go-auth-template-v2/internal/auth"
+	"github.com/joacolabadie/go-auth-template-v2/internal/health"
+	refreshtoken "github.com/joacolabadie/go-auth-template-v2/internal/refresh_token"
+	"github.com/joacolabadie/go-auth-template-v2/internal/routes"
+	"github.com/joacolabadie/go-auth-template-v2/internal/storage"
+	"github.com/joacolabadie/go-auth-template-v2/internal/user"
+	"github.com/joacolabadie/go-auth-template-v2/pkg/client"
+	"github.com/labstack/echo/v4"
+)
+
+// newRouterServer serves the real routes backed by memory repositories, so the
+// client is checked against the request bodies the API actually accepts.
+func newRouterServer(t *testing.T) (*user.MemoryUserRepository, *client.Client) {
+	t.Helper()
+
+	userRepo := user.NewMemoryUserRepository()
+	authService := auth.NewService(storage.NopTransactor{}, userRepo, refreshtoken.NewMemoryRefreshTokenRepository(), auth.NewHMACKey("test-secret"), "http://localhost", "test", time.Minute, time.Hour, nil)
+
+	e := echo.New()
+	e.HTTPErrorHandler = apierror.HTTPErrorHandler
+
+	routes.RegisterRoutes(
+		e,
+		authService,
+		auth.NewPersonalAccessTokenService(nil, nil),
+		auth.NewHandler(authService, nil, "test", nil),
+		auth.NewMagicLinkHandler(nil, nil, "test"),
+		auth.NewOTPHandler(nil, "test"),
+		auth.NewEmailChangeHandler(nil),
+		auth.NewAccountHandler(nil, "test"),
+		auth.NewUsernameHandler(auth.NewUsernameService(nil, nil, time.Hour)),
+		auth.NewPersonalAccessTokenHandler(nil),
+		auth.NewSessionHandler(nil),
+		user.NewHandler(userRepo, user.NewProfileService(nil, nil, user.MetadataRules{})),
+		health.NewHandler(time.Second),
+	)
+
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return userRepo, c
+}
+
+func TestLoginAgainstRouter(t *testing.T) {
+	userRepo, c := newRouterServer(t)
+	ctx := context.Background()
+
+	userID, err := c.Register(ctx, "user@example.com", "password")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := userRepo.UpdateUsername(ctx, userID, "someone"); err != nil {
+		t.Fatalf("UpdateUsername: %v", err)
+	}
+
+	logins := map[string]func() (*client.LoginResult, error){
+		"email": func() (*client.LoginResult, error) {
+			return c.Login(ctx, "user@example.com", "password")
+		},
+		"username": func() (*client.LoginResult, error) {
+			return c.LoginWithUsername(ctx, "someone", "password")
+		},
+	}
+
+	for name, login := range logins {
+		t.Run(name, func(t *testing.T) {
+			result, err := login()
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if result.MFARequired {
+				t.Fatal("MFARequired = true, want false")
+			}
+
+			profile, err := c.Profile(ctx)
+			if err != nil {
+				t.Fatalf("Profile: %v", err)
+			}
+			if profile.ID != userID {
+				t.Errorf("Profile ID = %v, want %v", profile.ID, userID)
+			}
+		})
+	}
+
+	if _, err := c.LoginWithUsername(ctx, "someone", "wrong-password"); !errors.Is(err, client.ErrInvalidCredentials) {
+		t.Errorf("LoginWithUsername with a wrong password error = %v, want client.ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,28 @@
+package verifier
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware verifies the access token and sets the claims under "claims"
+// and the user ID under "userID", the key the auth API's own handlers use.
+// Failures are returned as *echo.HTTPError so the service's error handler
+// renders them.
+func (v *Verifier) EchoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			claims, err := v.Verify(req.Context(), TokenFromRequest(req))
+			if err != nil {
+				return echo.NewHTTPError(StatusCode(err), err.Error()).SetInternal(err)
+			}
+
+			c.Set("claims", claims)
+			c.Set("userID", claims.UserID)
+			c.SetRequest(req.WithContext(NewContext(req.Context(), claims)))
+
+			return next(c)
+		}
+	}
+}
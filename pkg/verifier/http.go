@@ -0,0 +1,56 @@
+package verifier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Middleware rejects requests without a valid access token and stores the
+// claims of the rest in the request context, see ClaimsFromContext.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := v.Verify(r.Context(), TokenFromRequest(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
+
+// StatusCode maps a Verify error to the HTTP status a service should answer with
+func StatusCode(err error) int {
+	if errors.Is(err, ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusUnauthorized
+}
+
+// errorCode matches the problem codes used by the auth API itself
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		return "unauthorized"
+	case errors.Is(err, ErrExpiredToken):
+		return "expired_token"
+	case errors.Is(err, ErrUnavailable):
+		return "service_unavailable"
+	default:
+		return "invalid_token"
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, `{"type":"urn:problem-type:%[1]s","title":%[2]q,"status":%[3]d,"code":%[1]q}`, errorCode(err), http.StatusText(status), status)
+}
@@ -0,0 +1,63 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+}
+
+type introspectionResponse struct {
+	Active bool `json:"active"`
+	jwt.RegisteredClaims
+	AppMetadata  map[string]any `json:"app_metadata,omitempty"`
+	UserMetadata map[string]any `json:"user_metadata,omitempty"`
+}
+
+func (i *introspector) introspect(ctx context.Context, token string) (*Claims, error) {
+	form := url.Values{"token": {token}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(i.clientID, i.clientSecret)
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to introspect token: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: failed to introspect token: unexpected status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var result introspectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode introspection response: %w", ErrUnavailable, err)
+	}
+
+	if !result.Active {
+		return nil, ErrInvalidToken
+	}
+
+	return &Claims{
+		RegisteredClaims: result.RegisteredClaims,
+		AppMetadata:      result.AppMetadata,
+		UserMetadata:     result.UserMetadata,
+	}, nil
+}
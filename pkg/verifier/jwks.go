@@ -0,0 +1,231 @@
+package verifier
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var supportedAlgorithms = []string{"EdDSA", "RS256", "RS384", "RS512", "ES256", "ES384", "PS256"}
+
+// Unknown key IDs trigger a refetch so rotated keys are picked up right away,
+// but no more often than this so garbage tokens can't hammer the JWKS endpoint
+const minRefetchInterval = 30 * time.Second
+
+type keySet struct {
+	url             string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]any
+	fetchedAt time.Time
+
+	fetchMu sync.Mutex
+}
+
+func newKeySet(url string, httpClient *http.Client, refreshInterval time.Duration) *keySet {
+	return &keySet{
+		url:             url,
+		httpClient:      httpClient,
+		refreshInterval: refreshInterval,
+	}
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (any, error) {
+	key, found, age := s.lookup(kid)
+	if found && age < s.refreshInterval {
+		return key, nil
+	}
+	if !found && age < minRefetchInterval {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		// Keep serving the last known keys while the endpoint is unreachable
+		if found {
+			return key, nil
+		}
+
+		return nil, err
+	}
+
+	key, found, _ = s.lookup(kid)
+	if !found {
+		return nil, ErrInvalidToken
+	}
+
+	return key, nil
+}
+
+// empty reports whether the server publishes no keys, i.e. it signs tokens
+// with an HMAC secret and they can only be checked through introspection.
+func (s *keySet) empty(ctx context.Context) bool {
+	s.mu.RLock()
+	stale := time.Since(s.fetchedAt) >= s.refreshInterval
+	s.mu.RUnlock()
+
+	if stale {
+		_ = s.fetch(ctx)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return !s.fetchedAt.IsZero() && len(s.keys) == 0
+}
+
+func (s *keySet) lookup(kid string) (any, bool, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	age := time.Since(s.fetchedAt)
+
+	// Tokens without a kid are accepted when there is only one candidate
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true, age
+		}
+	}
+
+	key, ok := s.keys[kid]
+
+	return key, ok, age
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	// Another caller may have refreshed the keys while this one waited
+	s.mu.RLock()
+	recent := time.Since(s.fetchedAt) < minRefetchInterval
+	s.mu.RUnlock()
+	if recent {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to fetch JWKS: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: failed to fetch JWKS: unexpected status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("%w: failed to decode JWKS: %w", ErrUnavailable, err)
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		// Keys of unsupported types are skipped rather than failing the whole set
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,217 @@
+// Package verifier lets other services trust access tokens issued by the auth
+// API without sharing its signing secret. Tokens are verified locally against
+// the published JWKS, or through the introspection endpoint when the API signs
+// with an HMAC secret.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMissingToken = errors.New("missing access token")
+	ErrInvalidToken = errors.New("invalid access token")
+	ErrExpiredToken = errors.New("access token has expired")
+	// ErrUnavailable means the keys or the introspection endpoint couldn't be
+	// reached, so the token could be valid
+	ErrUnavailable = errors.New("token verification unavailable")
+)
+
+type Config struct {
+	// JWKSURL is usually <PUBLIC_URL>/.well-known/jwks.json
+	JWKSURL string
+	// IntrospectionURL is usually <PUBLIC_URL>/api/auth/introspect. It is used
+	// when JWKSURL is empty or the key set has no keys.
+	IntrospectionURL string
+	// ClientID and ClientSecret authenticate introspection requests, as one of
+	// the auth API's INTROSPECTION_CLIENTS
+	ClientID     string
+	ClientSecret string
+
+	Issuer   string
+	Audience string
+
+	// RefreshInterval is how long a fetched key set is trusted, 5 minutes by default
+	RefreshInterval time.Duration
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway     time.Duration
+	HTTPClient *http.Client
+}
+
+type Claims struct {
+	UserID uuid.UUID `json:"-"`
+	jwt.RegisteredClaims
+
+	// The metadata keys the auth API is configured to embed, see
+	// JWT_METADATA_CLAIMS. Values in UserMetadata are set by the user.
+	AppMetadata  map[string]any `json:"app_metadata,omitempty"`
+	UserMetadata map[string]any `json:"user_metadata,omitempty"`
+}
+
+type Verifier struct {
+	cfg          Config
+	keys         *keySet
+	introspector *introspector
+}
+
+func New(cfg Config) (*Verifier, error) {
+	if cfg.JWKSURL == "" && cfg.IntrospectionURL == "" {
+		return nil, errors.New("verifier: either JWKSURL or IntrospectionURL is required")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("verifier: Issuer and Audience are required")
+	}
+	if cfg.IntrospectionURL != "" && (cfg.ClientID == "" || cfg.ClientSecret == "") {
+		return nil, errors.New("verifier: ClientID and ClientSecret are required with IntrospectionURL")
+	}
+
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = 5 * time.Minute
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := &Verifier{cfg: cfg}
+
+	if cfg.JWKSURL != "" {
+		v.keys = newKeySet(cfg.JWKSURL, cfg.HTTPClient, cfg.RefreshInterval)
+	}
+	if cfg.IntrospectionURL != "" {
+		v.introspector = &introspector{
+			url:          cfg.IntrospectionURL,
+			clientID:     cfg.ClientID,
+			clientSecret: cfg.ClientSecret,
+			httpClient:   cfg.HTTPClient,
+		}
+	}
+
+	return v, nil
+}
+
+// Verify checks the token's signature, issuer, audience, expiry and
+// not-before time, returning ErrMissingToken, ErrExpiredToken or
+// ErrInvalidToken when it can't be trusted and ErrUnavailable when the auth
+// API couldn't be reached to find out.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	var claims *Claims
+	var err error
+
+	if v.keys != nil && (v.introspector == nil || !v.keys.empty(ctx)) {
+		claims, err = v.verifyLocally(ctx, token)
+	} else {
+		claims, err = v.introspector.introspect(ctx, token)
+		if err == nil {
+			err = v.validateClaims(claims)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims.UserID = userID
+
+	return claims, nil
+}
+
+func (v *Verifier) verifyLocally(ctx context.Context, tokenString string) (*Claims, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		return v.keys.key(ctx, kid)
+	}
+
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		keyFunc,
+		jwt.WithValidMethods(supportedAlgorithms),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.cfg.Leeway),
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return claims, nil
+}
+
+// validateClaims applies the same checks as local verification to claims the
+// server reported through introspection.
+func (v *Verifier) validateClaims(claims *Claims) error {
+	validator := jwt.NewValidator(
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.cfg.Leeway),
+	)
+
+	return mapError(validator.Validate(claims))
+}
+
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrExpiredToken
+	case errors.Is(err, ErrExpiredToken), errors.Is(err, ErrInvalidToken), errors.Is(err, ErrUnavailable):
+		return err
+	default:
+		return errors.Join(ErrInvalidToken, err)
+	}
+}
+
+// TokenFromRequest reads a bearer token from the Authorization header, falling
+// back to the access_token cookie set by the auth API for browsers.
+func TokenFromRequest(r *http.Request) string {
+	if token, ok := bearerToken(r.Header.Get("Authorization")); ok {
+		return token
+	}
+
+	if cookie, err := r.Cookie("access_token"); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+
+	return claims, ok
+}
@@ -0,0 +1,543 @@
+package verifier_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/joacolabadie/go-auth-template-v2/pkg/verifier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	issuer   = "http://localhost"
+	audience = "test"
+)
+
+type signer struct {
+	kid        string
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func newSigner(t *testing.T, kid string) signer {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	return signer{kid: kid, privateKey: privateKey, publicKey: publicKey}
+}
+
+func (s signer) jwk() map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"x":   base64.RawURLEncoding.EncodeToString(s.publicKey),
+		"kid": s.kid,
+		"use": "sig",
+		"alg": "EdDSA",
+	}
+}
+
+// sign issues a token like the auth API does, with mutate adjusting the
+// claims for the case under test
+func (s signer) sign(t *testing.T, mutate func(*jwt.RegisteredClaims)) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   uuid.NewString(),
+		Issuer:    issuer,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+	if mutate != nil {
+		mutate(&claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	if s.kid != "" {
+		token.Header["kid"] = s.kid
+	}
+
+	signed, err := token.SignedString(s.privateKey)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	return signed
+}
+
+// jwksServer publishes the given signers and counts how often it's fetched
+func jwksServer(t *testing.T, signers ...signer) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var fetches atomic.Int32
+	keys := []map[string]string{}
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &fetches
+}
+
+func newVerifier(t *testing.T, cfg verifier.Config) *verifier.Verifier {
+	t.Helper()
+
+	cfg.Issuer = issuer
+	cfg.Audience = audience
+
+	v, err := verifier.New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return v
+}
+
+func TestVerifyClaims(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, _ := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", key.sign(t, nil), nil},
+		{"missing", "", verifier.ErrMissingToken},
+		{"malformed", "not-a-jwt", verifier.ErrInvalidToken},
+		{"wrong issuer", key.sign(t, func(c *jwt.RegisteredClaims) { c.Issuer = "http://evil" }), verifier.ErrInvalidToken},
+		{"wrong audience", key.sign(t, func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"other"} }), verifier.ErrInvalidToken},
+		{"not yet valid", key.sign(t, func(c *jwt.RegisteredClaims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) }), verifier.ErrInvalidToken},
+		{"expired", key.sign(t, func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }), verifier.ErrExpiredToken},
+		{"without expiry", key.sign(t, func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil }), verifier.ErrInvalidToken},
+		{"subject not a user ID", key.sign(t, func(c *jwt.RegisteredClaims) { c.Subject = "admin" }), verifier.ErrInvalidToken},
+		{"unknown signer", newSigner(t, "key-1").sign(t, nil), verifier.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.UserID.String() != claims.Subject {
+				t.Errorf("UserID = %s, want %s", claims.UserID, claims.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, _ := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL, Leeway: time.Minute})
+
+	token := key.sign(t, func(c *jwt.RegisteredClaims) {
+		c.NotBefore = jwt.NewNumericDate(time.Now().Add(30 * time.Second))
+		c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+	})
+
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify within leeway: %v", err)
+	}
+}
+
+func TestKeySetIsCached(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, fetches := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+	for range 3 {
+		if _, err := v.Verify(context.Background(), key.sign(t, nil)); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestUnknownKeyIDRefetchIsThrottled(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, fetches := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+	// Garbage tokens with made-up key IDs must not each cost a JWKS request
+	for i := range 5 {
+		forged := newSigner(t, "forged-"+string(rune('a'+i)))
+		if _, err := v.Verify(context.Background(), forged.sign(t, nil)); !errors.Is(err, verifier.ErrInvalidToken) {
+			t.Fatalf("Verify error = %v, want verifier.ErrInvalidToken", err)
+		}
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestTokenWithoutKeyID(t *testing.T) {
+	t.Run("single key", func(t *testing.T) {
+		key := newSigner(t, "key-1")
+		server, _ := jwksServer(t, key)
+		v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+		withoutKid := signer{privateKey: key.privateKey, publicKey: key.publicKey}
+		if _, err := v.Verify(context.Background(), withoutKid.sign(t, nil)); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+	})
+
+	t.Run("several keys", func(t *testing.T) {
+		current, previous := newSigner(t, "key-2"), newSigner(t, "key-1")
+		server, _ := jwksServer(t, current, previous)
+		v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+		// Both keys must still be usable by their ID during a rotation
+		for _, key := range []signer{current, previous} {
+			if _, err := v.Verify(context.Background(), key.sign(t, nil)); err != nil {
+				t.Errorf("Verify with %s: %v", key.kid, err)
+			}
+		}
+
+		withoutKid := signer{privateKey: current.privateKey, publicKey: current.publicKey}
+		if _, err := v.Verify(context.Background(), withoutKid.sign(t, nil)); !errors.Is(err, verifier.ErrInvalidToken) {
+			t.Errorf("Verify error = %v, want verifier.ErrInvalidToken", err)
+		}
+	})
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+	if _, err := v.Verify(context.Background(), newSigner(t, "key-1").sign(t, nil)); !errors.Is(err, verifier.ErrUnavailable) {
+		t.Errorf("Verify error = %v, want verifier.ErrUnavailable", err)
+	}
+}
+
+// introspectionServer answers for the client "api" with the given response
+// and counts the requests
+func introspectionServer(t *testing.T, statusCode int, response map[string]any) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if id, secret, ok := r.BasicAuth(); !ok || id != "api" || secret != "introspection-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PostFormValue("token") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func activeResponse(mutate func(map[string]any)) map[string]any {
+	response := map[string]any{
+		"active": true,
+		"sub":    uuid.NewString(),
+		"iss":    issuer,
+		"aud":    audience,
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"app_metadata": map[string]any{
+			"plan": "pro",
+		},
+	}
+	if mutate != nil {
+		mutate(response)
+	}
+
+	return response
+}
+
+func TestIntrospection(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		response   map[string]any
+		clientID   string
+		wantErr    error
+	}{
+		{"active", http.StatusOK, activeResponse(nil), "api", nil},
+		{"inactive", http.StatusOK, map[string]any{"active": false}, "api", verifier.ErrInvalidToken},
+		{"wrong issuer", http.StatusOK, activeResponse(func(r map[string]any) { r["iss"] = "http://evil" }), "api", verifier.ErrInvalidToken},
+		{"wrong audience", http.StatusOK, activeResponse(func(r map[string]any) { r["aud"] = "other" }), "api", verifier.ErrInvalidToken},
+		{"not yet valid", http.StatusOK, activeResponse(func(r map[string]any) { r["nbf"] = time.Now().Add(time.Hour).Unix() }), "api", verifier.ErrInvalidToken},
+		{"expired", http.StatusOK, activeResponse(func(r map[string]any) { r["exp"] = time.Now().Add(-time.Hour).Unix() }), "api", verifier.ErrExpiredToken},
+		{"server error", http.StatusInternalServerError, nil, "api", verifier.ErrUnavailable},
+		{"rejected client", http.StatusOK, activeResponse(nil), "other", verifier.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := introspectionServer(t, tt.statusCode, tt.response)
+			v := newVerifier(t, verifier.Config{
+				IntrospectionURL: server.URL,
+				ClientID:         tt.clientID,
+				ClientSecret:     "introspection-secret",
+			})
+
+			claims, err := v.Verify(context.Background(), "opaque-token")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.AppMetadata["plan"] != "pro" {
+				t.Errorf("AppMetadata = %v, want plan pro", claims.AppMetadata)
+			}
+		})
+	}
+}
+
+func TestIntrospectionFallback(t *testing.T) {
+	t.Run("empty key set", func(t *testing.T) {
+		// An API signing with an HMAC secret publishes no keys
+		jwks, _ := jwksServer(t)
+		introspection, requests := introspectionServer(t, http.StatusOK, activeResponse(nil))
+		v := newVerifier(t, verifier.Config{
+			JWKSURL:          jwks.URL,
+			IntrospectionURL: introspection.URL,
+			ClientID:         "api",
+			ClientSecret:     "introspection-secret",
+		})
+
+		if _, err := v.Verify(context.Background(), "opaque-token"); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("introspection called %d times, want 1", got)
+		}
+	})
+
+	t.Run("published keys", func(t *testing.T) {
+		key := newSigner(t, "key-1")
+		jwks, _ := jwksServer(t, key)
+		introspection, requests := introspectionServer(t, http.StatusOK, activeResponse(nil))
+		v := newVerifier(t, verifier.Config{
+			JWKSURL:          jwks.URL,
+			IntrospectionURL: introspection.URL,
+			ClientID:         "api",
+			ClientSecret:     "introspection-secret",
+		})
+
+		if _, err := v.Verify(context.Background(), key.sign(t, nil)); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if got := requests.Load(); got != 0 {
+			t.Errorf("introspection called %d times, want 0", got)
+		}
+	})
+}
+
+func TestNewRequiresClientCredentialsForIntrospection(t *testing.T) {
+	_, err := verifier.New(verifier.Config{IntrospectionURL: "http://localhost/api/auth/introspect", Issuer: issuer, Audience: audience})
+	if err == nil {
+		t.Error("New without client credentials succeeded, want an error")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, _ := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := verifier.ClaimsFromContext(r.Context())
+		if !ok {
+			t.Error("claims missing from the request context")
+			return
+		}
+		w.Write([]byte(claims.UserID.String()))
+	}))
+
+	tests := []struct {
+		name     string
+		setup    func(r *http.Request)
+		status   int
+		wantCode string
+	}{
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key.sign(t, nil)) }, http.StatusOK, ""},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "access_token", Value: key.sign(t, nil)}) }, http.StatusOK, ""},
+		{"missing token", func(r *http.Request) {}, http.StatusUnauthorized, "unauthorized"},
+		{"invalid token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer not-a-jwt") }, http.StatusUnauthorized, "invalid_token"},
+		{"expired token", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+key.sign(t, func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }))
+		}, http.StatusUnauthorized, "expired_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header missing")
+			}
+
+			var problem struct {
+				Code   string `json:"code"`
+				Status int    `json:"status"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decoding problem: %v", err)
+			}
+			if problem.Code != tt.wantCode || problem.Status != tt.status {
+				t.Errorf("problem = %+v, want code %q and status %d", problem, tt.wantCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestMiddlewareUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without a verified token")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+newSigner(t, "key-1").sign(t, nil))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, _ := jwksServer(t, key)
+	v := newVerifier(t, verifier.Config{JWKSURL: server.URL})
+	interceptor := v.UnaryServerInterceptor()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(unavailable.Close)
+	unavailableInterceptor := newVerifier(t, verifier.Config{JWKSURL: unavailable.URL}).UnaryServerInterceptor()
+
+	tests := []struct {
+		name        string
+		interceptor grpc.UnaryServerInterceptor
+		md          metadata.MD
+		want        codes.Code
+	}{
+		{"valid token", interceptor, metadata.Pairs("authorization", "Bearer "+key.sign(t, nil)), codes.OK},
+		{"missing metadata", interceptor, nil, codes.Unauthenticated},
+		{"not a bearer token", interceptor, metadata.Pairs("authorization", "Basic YXBpOnNlY3JldA=="), codes.Unauthenticated},
+		{"invalid token", interceptor, metadata.Pairs("authorization", "Bearer not-a-jwt"), codes.Unauthenticated},
+		{"keys unavailable", unavailableInterceptor, metadata.Pairs("authorization", "Bearer "+key.sign(t, nil)), codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			handler := func(ctx context.Context, req any) (any, error) {
+				if _, ok := verifier.ClaimsFromContext(ctx); !ok {
+					t.Error("claims missing from the handler context")
+				}
+				return "ok", nil
+			}
+
+			_, err := tt.interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	key := newSigner(t, "key-1")
+	server, _ := jwksServer(t, key)
+	interceptor := newVerifier(t, verifier.Config{JWKSURL: server.URL}).StreamServerInterceptor()
+
+	handler := func(srv any, stream grpc.ServerStream) error {
+		if _, ok := verifier.ClaimsFromContext(stream.Context()); !ok {
+			t.Error("claims missing from the stream context")
+		}
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+key.sign(t, nil)))
+	if err := interceptor(nil, &serverStream{ctx: ctx}, info, handler); err != nil {
+		t.Errorf("interceptor with valid token: %v", err)
+	}
+
+	if err := interceptor(nil, &serverStream{ctx: context.Background()}, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("code = %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+}